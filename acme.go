@@ -0,0 +1,80 @@
+package powerdns
+
+import (
+	"context"
+	"strings"
+
+	"github.com/wrouesnel/go.powerdns/pdnstypes/records"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+// txtContent formats value as TXT record content, as for UpsertRecord.
+func txtContent(value string) string {
+	if strings.HasPrefix(value, `"`) {
+		return value
+	}
+	return records.FormatTXT(value)
+}
+
+// PresentTXT adds value to the TXT RRset at fqdn in the named zone, keeping any other values, as needed to present
+// an ACME DNS-01 challenge. The RRset is created if it does not exist, and its TTL is set to ttl. Presenting a value
+// which is already present does nothing. Names are made fully qualified if they are not already, and value is
+// quoted as for UpsertRecord.
+func (p *Client) PresentTXT(zone, fqdn, value string, ttl uint32) error {
+	return p.PresentTXTContext(context.Background(), zone, fqdn, value, ttl)
+}
+
+// PresentTXTContext is like PresentTXT but uses ctx for the requests it makes.
+func (p *Client) PresentTXTContext(ctx context.Context, zone, fqdn, value string, ttl uint32) error {
+	zone, fqdn, value = canonicalName(zone), canonicalName(fqdn), txtContent(value)
+
+	rrset, found, err := p.GetRRsetContext(ctx, zone, fqdn, "TXT")
+	if err != nil {
+		return err
+	}
+	if !found {
+		rrset = shared.RRset{Name: fqdn, Type: "TXT"}
+	}
+
+	for _, record := range rrset.Records {
+		if record.Content == value && !record.Disabled {
+			return nil
+		}
+	}
+
+	// A disabled copy of the value is replaced by an enabled one.
+	rrset.Records = rrset.Records.Difference(shared.Records{{Content: value, Disabled: true}}).
+		Union(shared.Records{{Content: value}})
+	rrset.TTL = ttl
+	rrset.Comments = nil
+	return p.ReplaceRecordsContext(ctx, zone, shared.RRsets{rrset})
+}
+
+// CleanupTXT removes value from the TXT RRset at fqdn in the named zone, keeping any other values, as needed to
+// clean up an ACME DNS-01 challenge. The RRset is deleted if no values remain. Cleaning up a value which is not
+// present does nothing. Names and value are handled as for PresentTXT.
+func (p *Client) CleanupTXT(zone, fqdn, value string) error {
+	return p.CleanupTXTContext(context.Background(), zone, fqdn, value)
+}
+
+// CleanupTXTContext is like CleanupTXT but uses ctx for the requests it makes.
+func (p *Client) CleanupTXTContext(ctx context.Context, zone, fqdn, value string) error {
+	zone, fqdn, value = canonicalName(zone), canonicalName(fqdn), txtContent(value)
+
+	rrset, found, err := p.GetRRsetContext(ctx, zone, fqdn, "TXT")
+	if err != nil || !found {
+		return err
+	}
+
+	remaining := rrset.Records.Difference(shared.Records{{Content: value}, {Content: value, Disabled: true}})
+	if len(remaining) == len(rrset.Records) {
+		return nil
+	}
+	if len(remaining) == 0 {
+		return p.DeleteRecordContext(ctx, zone, fqdn, "TXT")
+	}
+
+	rrset.Records = remaining
+	rrset.Comments = nil
+	return p.ReplaceRecordsContext(ctx, zone, shared.RRsets{rrset})
+}
@@ -0,0 +1,60 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+)
+
+const (
+	autoprimariesPathString = "autoprimaries"
+)
+
+// autoprimaryPath returns the API sub-path of the autoprimary with the given IP and nameserver.
+func autoprimaryPath(ip, nameserver string) string {
+	return fmt.Sprintf("%s/%s/%s", autoprimariesPathString, url.PathEscape(ip), url.PathEscape(nameserver))
+}
+
+// ListAutoprimaries returns all autoprimaries configured on the server. Requires PowerDNS 4.5 or later.
+func (p *Client) ListAutoprimaries() ([]authoritative.Autoprimary, error) {
+	return p.ListAutoprimariesContext(context.Background())
+}
+
+// ListAutoprimariesContext is like ListAutoprimaries but uses ctx for the requests it makes.
+func (p *Client) ListAutoprimariesContext(ctx context.Context) ([]authoritative.Autoprimary, error) {
+	autoprimaries := []authoritative.Autoprimary{}
+	if err := p.requireFeature(ctx, FeatureAutoprimaries); err != nil {
+		return autoprimaries, err
+	}
+
+	err := p.DoRequestContext(ctx, autoprimariesPathString, "GET", nil, &autoprimaries)
+	return autoprimaries, err
+}
+
+// CreateAutoprimary adds an autoprimary to the server.
+func (p *Client) CreateAutoprimary(req authoritative.Autoprimary) error {
+	return p.CreateAutoprimaryContext(context.Background(), req)
+}
+
+// CreateAutoprimaryContext is like CreateAutoprimary but uses ctx for the requests it makes.
+func (p *Client) CreateAutoprimaryContext(ctx context.Context, req authoritative.Autoprimary) error {
+	if err := p.requireFeature(ctx, FeatureAutoprimaries); err != nil {
+		return err
+	}
+	return p.DoRequestContext(ctx, autoprimariesPathString, "POST", &req, nil)
+}
+
+// DeleteAutoprimary removes the autoprimary with the given IP and nameserver.
+func (p *Client) DeleteAutoprimary(ip, nameserver string) error {
+	return p.DeleteAutoprimaryContext(context.Background(), ip, nameserver)
+}
+
+// DeleteAutoprimaryContext is like DeleteAutoprimary but uses ctx for the requests it makes.
+func (p *Client) DeleteAutoprimaryContext(ctx context.Context, ip, nameserver string) error {
+	if err := p.requireFeature(ctx, FeatureAutoprimaries); err != nil {
+		return err
+	}
+	return p.DoRequestContext(ctx, autoprimaryPath(ip, nameserver), "DELETE", nil, nil)
+}
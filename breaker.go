@@ -0,0 +1,89 @@
+package powerdns
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+)
+
+// breakerOutcome is how the result of a request affects a circuitBreaker.
+type breakerOutcome int
+
+const (
+	// breakerNeutral requests failed before reaching the server, or were cancelled by the caller.
+	breakerNeutral breakerOutcome = iota
+	breakerSuccess
+	breakerFailure
+)
+
+// circuitBreaker stops requests being sent once threshold consecutive requests have failed. After cooldown a single
+// request is let through to probe the server: if it succeeds the breaker closes, otherwise it stays open for
+// another cooldown.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mtx       sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow returns whether a request may be sent, and whether it is the probe of a half-open breaker. Every allowed
+// request must be followed by a call to record.
+func (b *circuitBreaker) allow() (allowed bool, probe bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.failures < b.threshold {
+		return true, false
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// record updates the breaker with the outcome of a request allowed by allow.
+func (b *circuitBreaker) record(probe bool, outcome breakerOutcome) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+
+	switch outcome {
+	case breakerSuccess:
+		b.failures = 0
+	case breakerFailure:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openUntil = b.now().Add(b.cooldown)
+		}
+	}
+}
+
+// requestOutcome classifies the result of openRequest for the breaker. Only unavailability of the server counts as
+// a failure: errors sending the request, and 429 or 5xx responses. ctx is the caller's context.
+func requestOutcome(ctx context.Context, status int, err error) breakerOutcome {
+	switch {
+	case status == http.StatusTooManyRequests || status >= 500:
+		return breakerFailure
+	case status != 0:
+		return breakerSuccess
+	case ctx.Err() == nil && errwrap.Contains(err, ErrClientRequestFailed.Error()):
+		return breakerFailure
+	default:
+		return breakerNeutral
+	}
+}
@@ -0,0 +1,64 @@
+package powerdns
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+const (
+	cacheFlushPathString = "cache/flush"
+)
+
+// FlushCache flushes all cache entries for the given domain and returns the number of entries flushed. The domain
+// is made fully qualified if it is not already. This works against either daemon type; FlushRecursorCache offers
+// the extra options of the recursor.
+func (p *Client) FlushCache(domain string) (int, error) {
+	return p.FlushCacheContext(context.Background(), domain)
+}
+
+// FlushCacheContext is like FlushCache but uses ctx for the requests it makes.
+func (p *Client) FlushCacheContext(ctx context.Context, domain string) (int, error) {
+	query := url.Values{}
+	query.Set("domain", canonicalName(domain))
+
+	result := shared.CacheFlushResult{}
+	err := p.DoRequestContext(ctx, cacheFlushPathString+"?"+query.Encode(), "PUT", nil, &result)
+	return result.Count, err
+}
+
+// RecursorCacheFlushOptions controls what FlushRecursorCache flushes.
+type RecursorCacheFlushOptions struct {
+	// Subtree also flushes all names under the domain.
+	Subtree bool
+	// Type restricts the flush to records of this type, e.g. "A". All types are flushed if it is empty.
+	Type string
+}
+
+// FlushRecursorCache flushes the cache entries of a recursor for the given domain as set in opts, and returns the
+// number of entries flushed. The domain is made fully qualified if it is not already.
+func (p *Client) FlushRecursorCache(domain string, opts RecursorCacheFlushOptions) (int, error) {
+	return p.FlushRecursorCacheContext(context.Background(), domain, opts)
+}
+
+// FlushRecursorCacheContext is like FlushRecursorCache but uses ctx for the requests it makes.
+func (p *Client) FlushRecursorCacheContext(ctx context.Context, domain string,
+	opts RecursorCacheFlushOptions) (int, error) {
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeRecursor); err != nil {
+		return 0, err
+	}
+
+	query := url.Values{}
+	query.Set("domain", canonicalName(domain))
+	if opts.Subtree {
+		query.Set("subtree", "true")
+	}
+	if opts.Type != "" {
+		query.Set("type", opts.Type)
+	}
+
+	result := shared.CacheFlushResult{}
+	err := p.DoRequestContext(ctx, cacheFlushPathString+"?"+query.Encode(), "PUT", nil, &result)
+	return result.Count, err
+}
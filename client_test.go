@@ -0,0 +1,1800 @@
+package powerdns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/recursor"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+	"github.com/wrouesnel/go.powerdns/powerdnstest"
+	. "gopkg.in/check.v1"
+)
+
+// ClientSuite tests the high-level Client methods against a stub HTTP server. Unlike AuthoritativeSuite it does
+// not need docker, and so only checks the client sends and decodes what we expect.
+type ClientSuite struct {
+	server  *httptest.Server
+	handler http.HandlerFunc
+	pdnsCli *Client
+}
+
+var _ = Suite(&ClientSuite{})
+
+func (s *ClientSuite) SetUpTest(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request to stub server: %s %s", r.Method, r.URL.String())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handler(w, r)
+	}))
+
+	pdnsCli, err := NewClient(s.server.URL, testAPIKey, true, time.Second)
+	c.Assert(err, IsNil)
+	s.pdnsCli = pdnsCli
+}
+
+func (s *ClientSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+// writeJSON writes a JSON response to the stub server's client.
+func writeJSON(c *C, w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	c.Assert(json.NewEncoder(w).Encode(body), IsNil)
+}
+
+func (s *ClientSuite) TestCreateZone(c *C) {
+	req := authoritative.ZoneRequestNative{
+		Zone: authoritative.Zone{
+			Zone: shared.Zone{
+				Name: "create.zone.",
+			},
+			Kind:       authoritative.KindNative,
+			SoaEdit:    authoritative.SoaEditValueInceptionIncrement,
+			SoaEditAPI: authoritative.SoaEditValueInceptionIncrement,
+		},
+		Nameservers: []string{"ns1.create.zone."},
+	}
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones")
+		c.Check(r.Header.Get("X-API-Key"), Equals, testAPIKey)
+
+		received := authoritative.ZoneRequestNative{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&received), IsNil)
+		c.Check(received.Nameservers, DeepEquals, req.Nameservers)
+
+		writeJSON(c, w, http.StatusCreated, authoritative.ZoneResponse{Zone: received.Zone, Serial: 1})
+	}
+
+	resp, err := s.pdnsCli.CreateZone(req)
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(resp.HeaderEquals(req.Zone), Equals, true)
+	c.Assert(resp.Serial, Equals, uint32(1))
+}
+
+func (s *ClientSuite) TestCreateZoneWithRRsets(c *C) {
+	rrsets := shared.RRsets{
+		{Name: "rrsets.zone.", Type: "NS", TTL: 3600, Records: shared.Records{{Content: "ns1.provider.net."}}},
+		{Name: "www.rrsets.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.1"}}},
+	}
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		received := authoritative.ZoneRequestNative{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&received), IsNil)
+		c.Check(received.Nameservers, HasLen, 0)
+		c.Check(received.Kind, Equals, authoritative.KindNative)
+		c.Check(received.RRsets, DeepEquals, rrsets)
+		writeJSON(c, w, http.StatusCreated, authoritative.ZoneResponse{Zone: received.Zone, Serial: 1})
+	}
+
+	resp, err := s.pdnsCli.CreateZoneWithRRsets("rrsets.zone", rrsets)
+	c.Assert(err, IsNil)
+	c.Assert(resp.RRsets.Equals(rrsets), Equals, true)
+
+	// Nameservers may not be mixed with an apex NS RRset, which the server would reject.
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+	}
+	_, err = s.pdnsCli.CreateZone(authoritative.ZoneRequestNative{
+		Zone: authoritative.Zone{
+			Zone: shared.Zone{Name: "RRsets.Zone.", RRsets: rrsets},
+			Kind: authoritative.KindNative,
+		},
+		Nameservers: []string{"ns1.rrsets.zone."},
+	})
+	c.Assert(err, Equals, ErrClientNameserversWithNS)
+}
+
+func (s *ClientSuite) TestCreateZoneNormalizesName(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		req := authoritative.ZoneRequestSlave{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&req), IsNil)
+		c.Check(req.Name, Equals, "not.canonical.")
+		writeJSON(c, w, http.StatusCreated, authoritative.ZoneResponse{Zone: req.Zone})
+	}
+
+	req := authoritative.ZoneRequestSlave{
+		Zone: authoritative.Zone{
+			Zone: shared.Zone{
+				Name: "Not.Canonical",
+			},
+			Kind: authoritative.KindSlave,
+		},
+	}
+
+	resp, err := s.pdnsCli.CreateSlaveZone(req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Name, Equals, "not.canonical.")
+}
+
+func (s *ClientSuite) TestCreateSlaveZoneSendsMasters(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&body), IsNil)
+		c.Check(body["masters"], DeepEquals, []interface{}{"192.0.2.1", "[2001:db8::1]:5300"})
+		writeJSON(c, w, http.StatusCreated, authoritative.ZoneResponse{
+			Zone:    authoritative.Zone{Zone: shared.Zone{Name: "secondary.zone."}, Kind: authoritative.KindSlave},
+			Masters: []string{"192.0.2.1", "[2001:db8::1]:5300"},
+		})
+	}
+
+	resp, err := s.pdnsCli.CreateSlaveZone(authoritative.ZoneRequestSlave{
+		Zone:    authoritative.Zone{Zone: shared.Zone{Name: "secondary.zone."}, Kind: authoritative.KindSlave},
+		Masters: []string{"192.0.2.1", "[2001:db8::1]:5300"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Masters, DeepEquals, []string{"192.0.2.1", "[2001:db8::1]:5300"})
+}
+
+func (s *ClientSuite) TestCreateZoneRejectsInvalidName(c *C) {
+	req := authoritative.ZoneRequestNative{
+		Zone: authoritative.Zone{
+			Zone: shared.Zone{
+				Name: "bad..zone.",
+			},
+			Kind: authoritative.KindNative,
+		},
+	}
+
+	_, err := s.pdnsCli.CreateZone(req)
+	c.Assert(err, NotNil)
+	c.Assert(errwrap.Contains(err, ErrClientZoneNameInvalid.Error()), Equals, true)
+	c.Assert(errwrap.Contains(err, shared.ErrZoneNameEmptyLabel.Error()), Equals, true)
+}
+
+func (s *ClientSuite) TestCreateZoneServerError(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(c, w, http.StatusUnprocessableEntity, shared.Error{Message: "Domain 'exists.zone.' already exists"})
+	}
+
+	req := authoritative.ZoneRequestMaster{
+		Zone: authoritative.Zone{
+			Zone: shared.Zone{
+				Name: "exists.zone.",
+			},
+			Kind: authoritative.KindMaster,
+		},
+	}
+
+	_, err := s.pdnsCli.CreateMasterZone(req)
+	c.Assert(err, NotNil)
+	c.Assert(errwrap.ContainsType(err, shared.Error{}), Equals, true)
+	c.Assert(errwrap.Contains(err, "Domain 'exists.zone.' already exists"), Equals, true)
+}
+
+func (s *ClientSuite) TestListZones(c *C) {
+	zones := []authoritative.ZoneResponse{
+		{Zone: authoritative.Zone{Zone: shared.Zone{Name: "one.zone."}, Kind: authoritative.KindNative}},
+		{Zone: authoritative.Zone{Zone: shared.Zone{Name: "two.zone."}, Kind: authoritative.KindMaster}},
+	}
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones")
+		writeJSON(c, w, http.StatusOK, zones)
+	}
+
+	zoneList, err := s.pdnsCli.ListZones()
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(len(zoneList), Equals, len(zones))
+	for idx := range zones {
+		c.Check(zoneList[idx].HeaderEquals(zones[idx].Zone), Equals, true)
+	}
+}
+
+func (s *ClientSuite) TestListZonesFiltered(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones")
+		c.Check(r.URL.Query().Get("zone"), Equals, "one.zone.")
+		c.Check(r.URL.Query().Get("rrsets"), Equals, "true")
+		writeJSON(c, w, http.StatusOK, []authoritative.ZoneResponse{
+			{Zone: authoritative.Zone{Zone: shared.Zone{Name: "one.zone."}, Kind: authoritative.KindNative}},
+		})
+	}
+
+	zoneList, err := s.pdnsCli.ListZonesFiltered(ListZonesOptions{NameFilter: "one.zone.", IncludeRRsets: true})
+	c.Assert(err, IsNil)
+	c.Assert(len(zoneList), Equals, 1)
+	c.Assert(zoneList[0].Name, Equals, "one.zone.")
+}
+
+func (s *ClientSuite) TestZoneExists(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones")
+		c.Check(r.URL.Query().Get("rrsets"), Equals, "false")
+		zones := []authoritative.ZoneResponse{}
+		if r.URL.Query().Get("zone") == "exists.zone." {
+			zones = append(zones, authoritative.ZoneResponse{
+				Zone: authoritative.Zone{Zone: shared.Zone{Name: "exists.zone."}, Kind: authoritative.KindNative},
+			})
+		}
+		writeJSON(c, w, http.StatusOK, zones)
+	}
+
+	exists, err := s.pdnsCli.ZoneExists("exists.zone")
+	c.Assert(err, IsNil)
+	c.Assert(exists, Equals, true)
+
+	exists, err = s.pdnsCli.ZoneExists("missing.zone.")
+	c.Assert(err, IsNil)
+	c.Assert(exists, Equals, false)
+}
+
+func (s *ClientSuite) TestListZonesWithoutRRsets(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/servers/localhost":
+			writeJSON(c, w, http.StatusOK, shared.ServerInfo{ID: "localhost",
+				DaemonType: shared.DaemonTypeAuthoritative, Version: "4.1.14"})
+		case "/api/v1/servers/localhost/zones":
+			c.Check(r.URL.RawQuery, Equals, "rrsets=false")
+			// Respond like a server which ignores the parameter
+			rrsets := shared.RRsets{{Name: "one.zone.", Type: "SOA"}}
+			writeJSON(c, w, http.StatusOK, []authoritative.ZoneResponse{
+				{Zone: authoritative.Zone{Zone: shared.Zone{Name: "one.zone.", RRsets: rrsets}, Kind: authoritative.KindNative}},
+				{Zone: authoritative.Zone{Zone: shared.Zone{Name: "two.zone."}, Kind: authoritative.KindNative}},
+			})
+		default:
+			c.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}
+
+	// Servers which predate rrsets=false are not refused, since the parameter is only an optimisation.
+	for _, pdnsCli := range []*Client{s.pdnsCli, s.pdnsCli.WithDaemonTypeCheck()} {
+		zoneList, err := pdnsCli.ListZonesFiltered(ListZonesOptions{})
+		c.Assert(err, IsNil)
+		c.Assert(len(zoneList), Equals, 2)
+		for _, zone := range zoneList {
+			c.Check(zone.RRsets, IsNil)
+		}
+	}
+
+	supported, err := s.pdnsCli.Supports(FeatureRRsetsFalse)
+	c.Assert(err, IsNil)
+	c.Assert(supported, Equals, false)
+}
+
+func (s *ClientSuite) TestIterZones(c *C) {
+	zones := []authoritative.ZoneResponse{
+		{Zone: authoritative.Zone{Zone: shared.Zone{Name: "one.zone."}, Kind: authoritative.KindNative}},
+		{Zone: authoritative.Zone{Zone: shared.Zone{Name: "two.zone."}, Kind: authoritative.KindMaster}},
+	}
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones")
+		writeJSON(c, w, http.StatusOK, zones)
+	}
+
+	next, err := s.pdnsCli.IterZones(context.Background())
+	c.Assert(err, IsNil)
+
+	for idx := range zones {
+		zone, err := next()
+		c.Assert(err, IsNil)
+		c.Check(zone.HeaderEquals(zones[idx].Zone), Equals, true)
+	}
+
+	_, err = next()
+	c.Assert(err, Equals, io.EOF)
+	_, err = next()
+	c.Assert(err, Equals, io.EOF)
+}
+
+func (s *ClientSuite) TestIterZonesStopEarly(c *C) {
+	release := make(chan struct{})
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `[{"name": "one.zone.", "kind": "Native"},`)
+		w.(http.Flusher).Flush()
+		// The rest of the list is never sent; the client must not wait for it.
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	next, err := s.pdnsCli.IterZones(ctx)
+	c.Assert(err, IsNil)
+
+	zone, err := next()
+	c.Assert(err, IsNil)
+	c.Assert(zone.Name, Equals, "one.zone.")
+
+	cancel()
+	_, err = next()
+	c.Assert(err, NotNil)
+	c.Assert(err, Not(Equals), io.EOF)
+}
+
+func (s *ClientSuite) TestIterZonesServerError(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(c, w, http.StatusInternalServerError, shared.Error{Message: "Backend failed"})
+	}
+
+	next, err := s.pdnsCli.IterZones(context.Background())
+	c.Assert(next, IsNil)
+	c.Assert(errwrap.Contains(err, "Backend failed"), Equals, true)
+}
+
+func (s *ClientSuite) TestGetZoneEscapesName(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.EscapedPath(), Equals, "/api/v1/servers/localhost/zones/0=2F26.2.0.192.in-addr.arpa.")
+		writeJSON(c, w, http.StatusOK, authoritative.ZoneResponse{
+			Zone: authoritative.Zone{Zone: shared.Zone{Name: "0/26.2.0.192.in-addr.arpa."}},
+		})
+	}
+
+	zone, err := s.pdnsCli.GetZone("0/26.2.0.192.in-addr.arpa.")
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(zone.Name, Equals, "0/26.2.0.192.in-addr.arpa.")
+}
+
+func (s *ClientSuite) TestGetZoneNotFound(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(c, w, http.StatusNotFound, shared.Error{Message: "Could not find domain 'missing.zone.'"})
+	}
+
+	_, err := s.pdnsCli.GetZone("missing.zone.")
+	c.Assert(err, NotNil)
+	c.Assert(errwrap.Contains(err, ErrNotFound.Error()), Equals, true)
+	c.Assert(IsNotFound(err), Equals, true)
+}
+
+func (s *ClientSuite) TestIsNotFound(c *C) {
+	c.Assert(IsNotFound(nil), Equals, false)
+	c.Assert(IsNotFound(ErrNotFound), Equals, true)
+	c.Assert(IsNotFound(ErrClientServerResponse), Equals, false)
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(c, w, http.StatusUnprocessableEntity, shared.Error{Message: "Invalid record"})
+	}
+
+	err := s.pdnsCli.DoRequest("zones/exists.zone.", "PATCH", nil, nil)
+	c.Assert(err, NotNil)
+	c.Assert(IsNotFound(err), Equals, false)
+}
+
+func (s *ClientSuite) TestIsUnauthorized(c *C) {
+	c.Assert(IsUnauthorized(nil), Equals, false)
+	c.Assert(IsUnauthorized(ErrUnauthorized), Equals, true)
+	c.Assert(IsUnauthorized(ErrClientServerResponse), Equals, false)
+
+	status := http.StatusUnauthorized
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		if status == http.StatusUnauthorized {
+			// PowerDNS answers a bad API key with a plain text body.
+			w.WriteHeader(status)
+			w.Write([]byte("Unauthorized")) // nolint: errcheck
+			return
+		}
+		writeJSON(c, w, status, shared.Error{Message: "Forbidden"})
+	}
+
+	for _, status = range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		_, err := s.pdnsCli.ListZones()
+		c.Assert(IsUnauthorized(err), Equals, true, Commentf("status %d", status))
+		c.Assert(IsNotFound(err), Equals, false)
+		code, _ := StatusCode(err)
+		c.Assert(code, Equals, status)
+	}
+
+	status = http.StatusUnprocessableEntity
+	_, err := s.pdnsCli.ListZones()
+	c.Assert(IsUnauthorized(err), Equals, false)
+}
+
+func (s *ClientSuite) TestServerErrorFrom(c *C) {
+	_, ok := ServerErrorFrom(nil)
+	c.Assert(ok, Equals, false)
+	_, ok = ServerErrorFrom(ErrClientServerResponse)
+	c.Assert(ok, Equals, false)
+
+	// PowerDNS reports the details of a 422 as a list of strings.
+	const body = `{"error": "RRset www.test.zone. IN A: Conflicts with pre-existing RRset", ` +
+		`"errors": ["RRset www.test.zone. IN A: Conflicts with pre-existing RRset"]}`
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(body)) // nolint: errcheck
+	}
+
+	err := s.pdnsCli.DoRequest("zones/test.zone.", "PATCH", nil, nil)
+	serverErr, ok := ServerErrorFrom(err)
+	c.Assert(ok, Equals, true)
+	c.Assert(serverErr, DeepEquals, shared.Error{
+		Message: "RRset www.test.zone. IN A: Conflicts with pre-existing RRset",
+		Errors:  []shared.Error{{Message: "RRset www.test.zone. IN A: Conflicts with pre-existing RRset"}},
+	})
+
+	// An error response which is not a PowerDNS error has no server error to extract.
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>Bad Gateway</html>")) // nolint: errcheck
+	}
+	_, err = s.pdnsCli.ListZones()
+	_, ok = ServerErrorFrom(err)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *ClientSuite) TestStandardErrors(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(c, w, http.StatusNotFound, shared.Error{Message: "Could not find domain 'missing.zone.'"})
+	}
+
+	_, err := s.pdnsCli.GetZone("missing.zone.")
+	c.Assert(errors.Is(err, ErrNotFound), Equals, true)
+	c.Assert(errors.Is(err, ErrClientServerResponse), Equals, false)
+
+	serverErr := ServerError{}
+	c.Assert(errors.As(err, &serverErr), Equals, true)
+	c.Assert(serverErr.StatusCode(), Equals, http.StatusNotFound)
+	reported := shared.Error{}
+	c.Assert(errors.As(err, &reported), Equals, true)
+	c.Assert(reported.Message, Equals, "Could not find domain 'missing.zone.'")
+
+	// Errors found before a request is sent are wrapped in the same way.
+	_, err = s.pdnsCli.CreateZone(authoritative.ZoneRequestNative{
+		Zone: authoritative.Zone{Zone: shared.Zone{Name: "bad..zone."}, Kind: authoritative.KindNative},
+	})
+	c.Assert(errors.Is(err, ErrClientZoneNameInvalid), Equals, true)
+
+	batchErr := error(ErrPatchBatch{Applied: 2, Err: err})
+	c.Assert(errors.Is(batchErr, err), Equals, true)
+}
+
+func (s *ClientSuite) TestDeleteZone(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "DELETE")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/delete.zone.")
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	err := s.pdnsCli.DeleteZone("delete.zone.")
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+}
+
+func (s *ClientSuite) TestDoRequestContextCancelled(c *C) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	err := s.pdnsCli.DoRequestContext(ctx, "zones", "GET", nil, nil)
+	c.Assert(err, NotNil)
+	c.Assert(errwrap.Contains(err, ErrClientRequestFailed.Error()), Equals, true)
+	c.Assert(ctx.Err(), Equals, context.DeadlineExceeded)
+}
+
+func (s *ClientSuite) TestContextMethodsCancelled(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The cancellation must reach the daemon type lookup as well as the request itself.
+	_, err := s.pdnsCli.WithDaemonTypeCheck().GetZoneContext(ctx, "cancelled.zone.")
+	c.Assert(errwrap.Contains(err, ErrClientRequestFailed.Error()), Equals, true)
+
+	err = s.pdnsCli.UpsertRecordContext(ctx, "cancelled.zone.", "www", "A", 300, "192.0.2.1")
+	c.Assert(errwrap.Contains(err, ErrClientRequestFailed.Error()), Equals, true)
+
+	_, err = s.pdnsCli.StatisticsContext(ctx)
+	c.Assert(errwrap.Contains(err, ErrClientRequestFailed.Error()), Equals, true)
+}
+
+func (s *ClientSuite) TestDoRequestRaw(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Accept"), Equals, "application/json")
+		switch r.URL.Path {
+		case "/api/v1/servers/localhost/new-endpoint":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"some": ["unmodelled", "data"]}`)) // nolint: errcheck
+		default:
+			writeJSON(c, w, http.StatusUnprocessableEntity, shared.Error{Message: "Bad request"})
+		}
+	}
+
+	raw, status, err := s.pdnsCli.DoRequestRaw("new-endpoint", "POST", map[string]string{"key": "value"})
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, http.StatusCreated)
+	decoded := map[string][]string{}
+	c.Assert(json.Unmarshal(raw, &decoded), IsNil)
+	c.Assert(decoded, DeepEquals, map[string][]string{"some": {"unmodelled", "data"}})
+
+	raw, status, err = s.pdnsCli.DoRequestRaw("other-endpoint", "GET", nil)
+	c.Assert(errwrap.Contains(err, ErrClientServerResponse.Error()), Equals, true)
+	c.Assert(status, Equals, http.StatusUnprocessableEntity)
+	c.Assert(raw, IsNil)
+}
+
+func (s *ClientSuite) TestStreamedRequestBody(c *C) {
+	rrsets := shared.RRsets{}
+	for i := 0; i < 1000; i++ {
+		rrsets = append(rrsets, shared.RRset{
+			Name:    fmt.Sprintf("host%d.stream.zone.", i),
+			Type:    "A",
+			TTL:     300,
+			Records: shared.Records{{Content: "192.0.2.1"}},
+		})
+	}
+	expected, err := json.Marshal(authoritative.PatchZoneRequest{RRSets: authoritative.NewPatchRRSets(rrsets,
+		authoritative.RRsetReplace)})
+	c.Assert(err, IsNil)
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		received, rerr := ioutil.ReadAll(r.Body)
+		c.Check(rerr, IsNil)
+		c.Check(string(received), Equals, string(expected))
+		w.WriteHeader(http.StatusNoContent)
+	}
+	c.Assert(s.pdnsCli.ReplaceRecords("stream.zone.", rrsets), IsNil)
+
+	// Encoding failures are still reported as such, not as a failed request.
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body) // nolint: errcheck
+		w.WriteHeader(http.StatusNoContent)
+	}
+	err = s.pdnsCli.DoRequest("zones", "POST", map[string]interface{}{"unencodable": make(chan int)}, nil)
+	c.Assert(errwrap.Contains(err, ErrClientRequestParsingError.Error()), Equals, true)
+}
+
+func (s *ClientSuite) TestExportZone(c *C) {
+	zoneFile := "export.zone.\t3600\tIN\tSOA\tns1.export.zone. hostmaster.export.zone. 1 10800 3600 604800 3600\n"
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/export.zone./export")
+		c.Check(r.Header.Get("Accept"), Equals, "text/plain")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(zoneFile)) // nolint: errcheck
+	}
+
+	exported, err := s.pdnsCli.ExportZone("export.zone.")
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(exported, Equals, zoneFile)
+}
+
+func (s *ClientSuite) TestEmptySuccessResponse(c *C) {
+	status := http.StatusNoContent
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}
+
+	// An empty body is success, and leaves the response untouched.
+	for _, status = range []int{http.StatusNoContent, http.StatusOK} {
+		response := shared.ActionResult{Result: "untouched"}
+		err := s.pdnsCli.DoRequest("zones/empty.zone./notify", "PUT", nil, &response)
+		c.Assert(err, IsNil, Commentf("status %d", status))
+		c.Assert(response.Result, Equals, "untouched")
+	}
+}
+
+func (s *ClientSuite) TestHTMLSuccessResponse(c *C) {
+	const page = "<!DOCTYPE html>\n<html><head><title>PowerDNS</title></head></html>"
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page)) // nolint: errcheck
+	}
+
+	_, err := s.pdnsCli.ListZones()
+	unreadable, ok := err.(ErrClientServerResponseUnreadable)
+	c.Assert(ok, Equals, true, Commentf("%v", err))
+	c.Assert(unreadable.ContentType(), Equals, "text/html; charset=utf-8")
+	c.Assert(unreadable.ResponseBodyString(), Equals, page)
+	c.Assert(strings.Contains(err.Error(), "text/html"), Equals, true)
+
+	// Raw responses are returned whatever their type.
+	stringResponse := ""
+	c.Assert(s.pdnsCli.DoRequest("zones/html.zone./export", "GET", nil, &stringResponse), IsNil)
+	c.Assert(stringResponse, Equals, page)
+}
+
+func (s *ClientSuite) TestDoRequestRawResponse(c *C) {
+	const body = "not json at all"
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Accept"), Equals, "application/json")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(body)) // nolint: errcheck
+	}
+
+	stringResponse := ""
+	err := s.pdnsCli.DoRequest("zones/raw.zone./export", "GET", nil, &stringResponse)
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(stringResponse, Equals, body)
+
+	bytesResponse := []byte{}
+	err = s.pdnsCli.DoRequest("zones/raw.zone./export", "GET", nil, &bytesResponse)
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(string(bytesResponse), Equals, body)
+}
+
+func (s *ClientSuite) TestDoRequestAcceptOverride(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Accept"), Equals, "text/plain")
+		w.Write([]byte("plain")) // nolint: errcheck
+	}
+
+	headers := http.Header{}
+	headers.Set("Accept", "text/plain")
+	pdnsCli, err := New(s.pdnsCli.endpoint, "localhost", nil, headers)
+	c.Assert(err, IsNil)
+
+	response := ""
+	err = pdnsCli.DoRequest("zones/raw.zone./export", "GET", nil, &response)
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(response, Equals, "plain")
+}
+
+func (s *ClientSuite) TestSetCryptoKeyActive(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/signed.zone.")
+			writeJSON(c, w, http.StatusOK, authoritative.ZoneResponse{
+				Zone: authoritative.Zone{Zone: shared.Zone{Name: "signed.zone."}, DNSsec: true},
+			})
+		case "PUT":
+			c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/signed.zone./cryptokeys/3")
+			received := map[string]interface{}{}
+			c.Assert(json.NewDecoder(r.Body).Decode(&received), IsNil)
+			c.Check(received, DeepEquals, map[string]interface{}{"active": true})
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			c.Errorf("unexpected method: %s", r.Method)
+		}
+	}
+
+	err := s.pdnsCli.SetCryptoKeyActive("signed.zone.", 3, true)
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+}
+
+func (s *ClientSuite) TestSetCryptoKeyActiveNotDNSSEC(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		writeJSON(c, w, http.StatusOK, authoritative.ZoneResponse{
+			Zone: authoritative.Zone{Zone: shared.Zone{Name: "unsigned.zone."}, DNSsec: false},
+		})
+	}
+
+	err := s.pdnsCli.SetCryptoKeyActive("unsigned.zone.", 1, false)
+	c.Assert(err, Equals, ErrClientZoneNotDNSSEC)
+}
+
+func (s *ClientSuite) TestCreateTSIGKeyGenerated(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/tsigkeys")
+
+		received := map[string]interface{}{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&received), IsNil)
+		_, hasKey := received["key"]
+		c.Check(hasKey, Equals, false, Commentf("empty key should be omitted so the server generates one"))
+
+		writeJSON(c, w, http.StatusCreated, authoritative.TSIGKey{
+			Name:      "transfer",
+			ID:        "transfer.",
+			Algorithm: "hmac-sha256",
+			Key:       "c2VjcmV0",
+			Type:      "TSIGKey",
+		})
+	}
+
+	key, err := s.pdnsCli.CreateTSIGKey(authoritative.TSIGKey{Name: "transfer", Algorithm: "hmac-sha256"})
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(key.ID, Equals, "transfer.")
+	c.Assert(key.Key, Equals, "c2VjcmV0")
+}
+
+func (s *ClientSuite) TestTSIGKeyPaths(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/tsigkeys/transfer.")
+		switch r.Method {
+		case "GET", "PUT":
+			writeJSON(c, w, http.StatusOK, authoritative.TSIGKey{Name: "transfer", ID: "transfer."})
+		case "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			c.Errorf("unexpected method: %s", r.Method)
+		}
+	}
+
+	_, err := s.pdnsCli.GetTSIGKey("transfer.")
+	c.Assert(err, IsNil)
+	_, err = s.pdnsCli.ChangeTSIGKey("transfer.", authoritative.TSIGKey{Name: "transfer", Algorithm: "hmac-sha512"})
+	c.Assert(err, IsNil)
+	c.Assert(s.pdnsCli.DeleteTSIGKey("transfer."), IsNil)
+}
+
+func (s *ClientSuite) TestStatistics(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/statistics")
+		w.Write([]byte(`[{"name": "uptime", "type": "StatisticItem", "value": "42"}]`)) // nolint: errcheck
+	}
+
+	stats, err := s.pdnsCli.Statistics()
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(stats, DeepEquals, []shared.StatisticItem{{Name: "uptime", Type: shared.StatisticTypeItem, Value: "42"}})
+}
+
+func (s *ClientSuite) TestConfig(c *C) {
+	setting := shared.ConfigSetting{Name: "api-readonly", Type: "ConfigSetting", Value: "no"}
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		switch r.URL.Path {
+		case "/api/v1/servers/localhost/config":
+			writeJSON(c, w, http.StatusOK, []shared.ConfigSetting{setting})
+		case "/api/v1/servers/localhost/config/api-readonly":
+			writeJSON(c, w, http.StatusOK, setting)
+		default:
+			c.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	}
+
+	settings, err := s.pdnsCli.Config()
+	c.Assert(err, IsNil)
+	c.Assert(settings, DeepEquals, []shared.ConfigSetting{setting})
+
+	single, err := s.pdnsCli.ConfigSetting("api-readonly")
+	c.Assert(err, IsNil)
+	c.Assert(single, DeepEquals, setting)
+}
+
+func (s *ClientSuite) TestServers(c *C) {
+	info := shared.ServerInfo{
+		ID:         "localhost",
+		DaemonType: shared.DaemonTypeAuthoritative,
+		Type:       "Server",
+		URL:        "/api/v1/servers/localhost",
+		Version:    "4.1.0",
+	}
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		switch r.URL.Path {
+		case "/api/v1/servers":
+			writeJSON(c, w, http.StatusOK, []shared.ServerInfo{info})
+		case "/api/v1/servers/localhost":
+			writeJSON(c, w, http.StatusOK, info)
+		default:
+			c.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	}
+
+	servers, err := s.pdnsCli.ListServers()
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(servers, DeepEquals, []shared.ServerInfo{info})
+
+	server, err := s.pdnsCli.ServerInfo()
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(server, DeepEquals, info)
+}
+
+func (s *ClientSuite) TestFlushCache(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "PUT")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/cache/flush")
+		c.Check(r.URL.Query().Get("domain"), Equals, "flush me.zone.")
+		writeJSON(c, w, http.StatusOK, shared.CacheFlushResult{Count: 7, Result: "Flushed cache."})
+	}
+
+	count, err := s.pdnsCli.FlushCache("flush me.zone")
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 7)
+}
+
+func (s *ClientSuite) TestFlushRecursorCache(c *C) {
+	daemonType := shared.DaemonType(shared.DaemonTypeRecursor)
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/servers/localhost":
+			writeJSON(c, w, http.StatusOK, shared.ServerInfo{ID: "localhost", DaemonType: daemonType})
+		case "/api/v1/servers/localhost/cache/flush":
+			c.Check(r.Method, Equals, "PUT")
+			c.Check(r.URL.Query().Get("domain"), Equals, "flush.zone.")
+			c.Check(r.URL.Query().Get("subtree"), Equals, "true")
+			c.Check(r.URL.Query().Get("type"), Equals, "AAAA")
+			writeJSON(c, w, http.StatusOK, shared.CacheFlushResult{Count: 3, Result: "Flushed cache."})
+		default:
+			c.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}
+
+	checkedCli := s.pdnsCli.WithDaemonTypeCheck()
+	count, err := checkedCli.FlushRecursorCache("flush.zone", RecursorCacheFlushOptions{Subtree: true, Type: "AAAA"})
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 3)
+
+	daemonType = shared.DaemonTypeAuthoritative
+	_, err = s.pdnsCli.ForServer("localhost").WithDaemonTypeCheck().FlushRecursorCache("flush.zone",
+		RecursorCacheFlushOptions{})
+	c.Assert(err, FitsTypeOf, ErrWrongDaemonType{})
+}
+
+func (s *ClientSuite) TestNewCopiesHeaders(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("X-API-Key"), Equals, testAPIKey)
+		w.Write([]byte("[]")) // nolint: errcheck
+	}
+
+	endpoint, err := url.Parse(s.server.URL)
+	c.Assert(err, IsNil)
+	headers := http.Header{"X-Api-Key": []string{testAPIKey}}
+	pdnsCli, err := New(endpoint, "localhost", nil, headers)
+	c.Assert(err, IsNil)
+
+	// Changing the headers after construction must not affect the client.
+	headers.Set("X-API-Key", "changed")
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+}
+
+func (s *ClientSuite) TestConcurrentRequests(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/servers/localhost":
+			writeJSON(c, w, http.StatusOK, shared.ServerInfo{ID: "localhost",
+				DaemonType: shared.DaemonTypeAuthoritative, Version: "4.4.0"})
+		case "/api/v1/servers/localhost/zones":
+			c.Check(r.Header.Get("X-API-Key"), Equals, testAPIKey)
+			w.Write([]byte("[]")) // nolint: errcheck
+		default:
+			c.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}
+
+	// A single client, including the derived client sharing its cache, must be usable from many goroutines. Run
+	// with -race for this to be meaningful.
+	checkedCli := s.pdnsCli.WithDaemonTypeCheck()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := s.pdnsCli.ListZones()
+			c.Check(err, IsNil)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := checkedCli.ListZonesFiltered(ListZonesOptions{})
+			c.Check(err, IsNil)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *ClientSuite) TestCreateForwardZone(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/servers/localhost":
+			writeJSON(c, w, http.StatusOK, shared.ServerInfo{ID: "localhost", DaemonType: shared.DaemonTypeRecursor})
+		case "/api/v1/servers/localhost/zones":
+			c.Check(r.Method, Equals, "POST")
+			body := map[string]interface{}{}
+			c.Assert(json.NewDecoder(r.Body).Decode(&body), IsNil)
+			c.Check(body["name"], Equals, "internal.corp.")
+			c.Check(body["kind"], Equals, "Forwarded")
+			c.Check(body["servers"], DeepEquals, []interface{}{"192.0.2.53", "192.0.2.54:5353"})
+			c.Check(body["recursion_desired"], Equals, true)
+			writeJSON(c, w, http.StatusCreated, body)
+		default:
+			c.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}
+
+	checkedCli := s.pdnsCli.WithDaemonTypeCheck()
+	zone, err := checkedCli.CreateForwardZone("Internal.Corp", []string{"192.0.2.53", "192.0.2.54:5353"}, true)
+	c.Assert(err, IsNil)
+	c.Assert(zone.Name, Equals, "internal.corp.")
+	c.Assert(zone.Servers, DeepEquals, []string{"192.0.2.53", "192.0.2.54:5353"})
+	c.Assert(zone.RecursionDesired, Equals, true)
+	c.Assert(zone.Kind, Equals, recursor.KindForwarded)
+
+	_, err = checkedCli.CreateForwardZone("internal.corp", nil, false)
+	c.Assert(err, Equals, ErrClientForwardServersEmpty)
+}
+
+func (s *ClientSuite) TestSearch(c *C) {
+	result := authoritative.SearchResult{
+		Content:    "192.0.2.1",
+		Name:       "orphan.search.zone.",
+		ObjectType: authoritative.SearchObjectTypeRecord,
+		Zone:       "search.zone.",
+		ZoneID:     "search.zone.",
+		Type:       "A",
+		TTL:        300,
+	}
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/search-data")
+		c.Check(r.URL.Query().Get("q"), Equals, "orphan*")
+		c.Check(r.URL.Query().Get("max"), Equals, "10")
+		c.Check(r.URL.Query().Get("object_type"), Equals, "record")
+		writeJSON(c, w, http.StatusOK, []authoritative.SearchResult{result})
+	}
+
+	results, err := s.pdnsCli.Search("orphan*", 10, "record")
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(results, DeepEquals, []authoritative.SearchResult{result})
+
+	_, err = s.pdnsCli.Search("orphan*", 10, "records")
+	c.Assert(err, Equals, ErrClientSearchTypeInvalid)
+}
+
+func (s *ClientSuite) TestNotifyZone(c *C) {
+	kind := authoritative.KindMaster
+	notified := false
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			writeJSON(c, w, http.StatusOK, authoritative.ZoneResponse{
+				Zone: authoritative.Zone{Zone: shared.Zone{Name: "notify.zone."}, Kind: kind},
+			})
+		case "PUT":
+			c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/notify.zone./notify")
+			notified = true
+			writeJSON(c, w, http.StatusOK, map[string]string{"result": "Notification queued"})
+		default:
+			c.Errorf("unexpected method: %s", r.Method)
+		}
+	}
+
+	c.Assert(s.pdnsCli.NotifyZone("notify.zone."), IsNil)
+	c.Assert(notified, Equals, true)
+
+	notified = false
+	kind = authoritative.KindSlave
+	c.Assert(s.pdnsCli.NotifyZone("notify.zone."), Equals, ErrClientZoneNotMaster)
+	c.Assert(notified, Equals, false)
+}
+
+func (s *ClientSuite) TestRectifyZone(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "PUT")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/rectify.zone./rectify")
+		writeJSON(c, w, http.StatusOK, shared.ActionResult{Result: "Rectified"})
+	}
+
+	result, err := s.pdnsCli.RectifyZone("rectify.zone.")
+	formatWrapErr(c, err)
+	c.Assert(err, IsNil)
+	c.Assert(result, Equals, "Rectified")
+}
+
+func (s *ClientSuite) TestPatchZoneHelpers(c *C) {
+	rrsets := shared.RRsets{
+		{Name: "www.patch.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.1"}}},
+	}
+	expectedChangeType := authoritative.RRsetReplace
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "PATCH")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/patch.zone.")
+
+		received := authoritative.PatchZoneRequest{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&received), IsNil)
+		c.Check(received.RRSets, DeepEquals, authoritative.NewPatchRRSets(rrsets, expectedChangeType))
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	c.Assert(s.pdnsCli.ReplaceRecords("patch.zone.", rrsets), IsNil)
+
+	expectedChangeType = authoritative.RRSetDelete
+	c.Assert(s.pdnsCli.DeleteRecords("patch.zone.", rrsets), IsNil)
+}
+
+func (s *ClientSuite) TestDaemonTypeCheck(c *C) {
+	serverInfoRequests := 0
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost")
+		serverInfoRequests++
+		writeJSON(c, w, http.StatusOK, shared.ServerInfo{ID: "localhost", DaemonType: shared.DaemonTypeRecursor})
+	}
+
+	checkedCli := s.pdnsCli.WithDaemonTypeCheck()
+
+	_, err := checkedCli.ListZones()
+	c.Assert(err, DeepEquals, ErrWrongDaemonType{
+		Expected: shared.DaemonTypeAuthoritative,
+		Actual:   shared.DaemonTypeRecursor,
+	})
+
+	// The daemon type should be cached after the first lookup
+	c.Assert(checkedCli.DeleteZone("some.zone."), FitsTypeOf, ErrWrongDaemonType{})
+	daemonType, err := s.pdnsCli.DaemonType()
+	c.Assert(err, IsNil)
+	c.Assert(daemonType, Equals, shared.DaemonType(shared.DaemonTypeRecursor))
+	c.Assert(serverInfoRequests, Equals, 1)
+}
+
+func (s *ClientSuite) TestSupports(c *C) {
+	serverInfoRequests := 0
+	autoprimaryRequests := 0
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/servers/localhost":
+			serverInfoRequests++
+			writeJSON(c, w, http.StatusOK, shared.ServerInfo{
+				ID:         "localhost",
+				DaemonType: shared.DaemonTypeAuthoritative,
+				Version:    "4.4.1",
+			})
+		case "/api/v1/servers/localhost/autoprimaries":
+			autoprimaryRequests++
+			writeJSON(c, w, http.StatusOK, []authoritative.Autoprimary{})
+		default:
+			c.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}
+
+	for feature, expected := range map[Feature]bool{
+		FeatureSearchData:    true,
+		FeatureRectify:       true,
+		FeatureRRsetsFalse:   true,
+		FeatureAutoprimaries: false,
+		Feature(-1):          false,
+	} {
+		supported, err := s.pdnsCli.Supports(feature)
+		c.Check(err, IsNil)
+		c.Check(supported, Equals, expected, Commentf("%s", feature))
+	}
+	c.Assert(serverInfoRequests, Equals, 1)
+
+	// Unchecked clients send the request regardless.
+	_, err := s.pdnsCli.ListAutoprimaries()
+	c.Assert(err, IsNil)
+	c.Assert(autoprimaryRequests, Equals, 1)
+
+	_, err = s.pdnsCli.WithDaemonTypeCheck().ListAutoprimaries()
+	c.Assert(err, DeepEquals, ErrUnsupportedFeature{Feature: FeatureAutoprimaries, Version: "4.4.1"})
+	c.Assert(err.Error(), Equals, "This PowerDNS version (4.4.1) does not support autoprimaries")
+	c.Assert(autoprimaryRequests, Equals, 1)
+	c.Assert(serverInfoRequests, Equals, 1)
+}
+
+func (s *ClientSuite) TestForServer(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/servers/localhost":
+			writeJSON(c, w, http.StatusOK, shared.ServerInfo{ID: "localhost", DaemonType: shared.DaemonTypeRecursor})
+		case "/api/v1/servers/other":
+			writeJSON(c, w, http.StatusOK, shared.ServerInfo{ID: "other", DaemonType: shared.DaemonTypeAuthoritative})
+		case "/api/v1/servers/other/zones":
+			c.Check(r.Header.Get("X-API-Key"), Equals, testAPIKey)
+			w.Write([]byte("[]")) // nolint: errcheck
+		default:
+			c.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}
+
+	checkedCli := s.pdnsCli.WithDaemonTypeCheck()
+	_, err := checkedCli.ListZones()
+	c.Assert(err, FitsTypeOf, ErrWrongDaemonType{})
+
+	// The copy must not inherit the cached daemon type of the original server
+	otherCli := checkedCli.ForServer("other")
+	c.Assert(otherCli.cli, Equals, checkedCli.cli)
+	_, err = otherCli.ListZones()
+	c.Assert(err, IsNil)
+
+	// The original is unchanged
+	_, err = checkedCli.ListZones()
+	c.Assert(err, FitsTypeOf, ErrWrongDaemonType{})
+}
+
+func (s *ClientSuite) TestServerErrorStatusCode(c *C) {
+	status := http.StatusUnauthorized
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(c, w, status, shared.Error{Message: "Unauthorized"})
+	}
+
+	for _, status = range []int{http.StatusUnauthorized, http.StatusUnprocessableEntity,
+		http.StatusInternalServerError, http.StatusMultipleChoices} {
+		err := s.pdnsCli.DoRequest("zones", "GET", nil, nil)
+		c.Assert(err, NotNil)
+
+		statusCode, found := StatusCode(err)
+		c.Assert(found, Equals, true)
+		c.Assert(statusCode, Equals, status)
+	}
+
+	_, found := StatusCode(ErrClientRequestFailed)
+	c.Assert(found, Equals, false)
+}
+
+func (s *ClientSuite) TestServerErrorRawBody(c *C) {
+	const body = `{"error": "", "errors": ["RRset www.test.zone. IN A: Conflicts with pre-existing RRset"]}`
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(body)) // nolint: errcheck
+	}
+
+	err := s.pdnsCli.DoRequest("zones/test.zone.", "PATCH", nil, nil)
+	c.Assert(err, NotNil)
+
+	serverErr, ok := errwrap.GetType(err, ServerError{}).(ServerError)
+	c.Assert(ok, Equals, true)
+	c.Assert(string(serverErr.RawBody()), Equals, body)
+}
+
+func (s *ClientSuite) TestSetRRsetComments(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "PATCH")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/test.zone.")
+
+		req := map[string][]map[string]interface{}{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&req), IsNil)
+		c.Assert(len(req["rrsets"]), Equals, 1)
+		rrset := req["rrsets"][0]
+		c.Check(rrset["name"], Equals, "www.test.zone.")
+		c.Check(rrset["type"], Equals, "A")
+		c.Check(rrset["changetype"], Equals, string(authoritative.RRsetReplace))
+		// Records must not be sent as an array, or they would be replaced too.
+		c.Check(rrset["records"], IsNil)
+		comments := rrset["comments"].([]interface{})
+		c.Assert(len(comments), Equals, 1)
+		c.Check(comments[0].(map[string]interface{})["content"], Equals, "TICKET-123")
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	err := s.pdnsCli.SetRRsetComments("test.zone.", "www.test.zone.", "A",
+		[]shared.Comment{{Content: "TICKET-123", Account: "ops"}})
+	c.Assert(err, IsNil)
+}
+
+func (s *ClientSuite) TestCreateOrUpdateZone(c *C) {
+	existing := authoritative.ZoneResponse{Zone: authoritative.Zone{Zone: shared.Zone{
+		Name: "test.zone.",
+		RRsets: shared.RRsets{
+			{Name: "test.zone.", Type: "SOA", TTL: 3600, Records: shared.Records{
+				{Content: "a.misconfigured.powerdns.server. hostmaster.test.zone. 1 10800 3600 604800 3600"},
+			}},
+			{Name: "same.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.1"}}},
+			{Name: "changed.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.2"}}},
+		},
+	}}}
+
+	desired := shared.RRsets{
+		{Name: "same.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.1"}}},
+		{Name: "changed.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.3"}}},
+		{Name: "new.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.4"}}},
+	}
+
+	patched := false
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			writeJSON(c, w, http.StatusConflict, shared.Error{Message: "Domain 'test.zone.' already exists"})
+		case "GET":
+			writeJSON(c, w, http.StatusOK, existing)
+		case "PATCH":
+			patched = true
+			req := authoritative.PatchZoneRequest{}
+			c.Assert(json.NewDecoder(r.Body).Decode(&req), IsNil)
+			c.Assert(req.RRSets.CopyToRRSets().Equals(desired[1:]), Equals, true)
+			for _, rrset := range req.RRSets {
+				c.Check(rrset.ChangeType, Equals, authoritative.RRsetReplace)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			c.Errorf("unexpected method %s", r.Method)
+		}
+	}
+
+	req := authoritative.ZoneRequestNative{Zone: authoritative.Zone{
+		Zone: shared.Zone{Name: "test.zone.", RRsets: desired},
+		Kind: authoritative.KindNative,
+	}}
+
+	_, err := s.pdnsCli.CreateOrUpdateZone(req)
+	c.Assert(err, IsNil)
+	c.Assert(patched, Equals, true)
+}
+
+func (s *ClientSuite) TestCreateOrUpdateZoneOtherError(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		writeJSON(c, w, http.StatusUnprocessableEntity, shared.Error{Message: "Invalid zone"})
+	}
+
+	req := authoritative.ZoneRequestNative{Zone: authoritative.Zone{Zone: shared.Zone{Name: "test.zone."}}}
+	_, err := s.pdnsCli.CreateOrUpdateZone(req)
+	c.Assert(errwrap.Contains(err, "Invalid zone"), Equals, true)
+}
+
+func (s *ClientSuite) TestPatchZoneBatched(c *C) {
+	rrsets := shared.RRsets{}
+	for i := 0; i < 5; i++ {
+		rrsets = append(rrsets, shared.RRset{
+			Name:    fmt.Sprintf("host%d.batch.zone.", i),
+			Type:    "A",
+			TTL:     300,
+			Records: shared.Records{{Content: fmt.Sprintf("192.0.2.%d", i)}},
+		})
+	}
+	changes := authoritative.NewPatchRRSets(rrsets, authoritative.RRsetReplace)
+
+	batches := []int{}
+	failBatch := -1
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "PATCH")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/batch.zone.")
+		req := authoritative.PatchZoneRequest{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&req), IsNil)
+		batches = append(batches, len(req.RRSets))
+		if len(batches)-1 == failBatch {
+			writeJSON(c, w, http.StatusUnprocessableEntity, shared.Error{Message: "Bad batch"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	c.Assert(s.pdnsCli.PatchZoneBatched("batch.zone.", changes, 2), IsNil)
+	c.Assert(batches, DeepEquals, []int{2, 2, 1})
+
+	batches = nil
+	c.Assert(s.pdnsCli.PatchZoneBatched("batch.zone.", changes, 0), IsNil)
+	c.Assert(batches, DeepEquals, []int{5})
+
+	// The first failure stops the patch and reports the progress made.
+	batches = nil
+	failBatch = 1
+	err := s.pdnsCli.PatchZoneBatched("batch.zone.", changes, 2)
+	batchErr, ok := err.(ErrPatchBatch)
+	c.Assert(ok, Equals, true)
+	c.Assert(batchErr.Applied, Equals, 2)
+	c.Assert(errwrap.Contains(err, "Bad batch"), Equals, true)
+	c.Assert(batches, DeepEquals, []int{2, 2})
+}
+
+func (s *ClientSuite) TestPlanZonePatch(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		// Planning must never modify the zone
+		c.Check(r.Method, Equals, "GET")
+		writeJSON(c, w, http.StatusOK, authoritative.ZoneResponse{Zone: authoritative.Zone{Zone: shared.Zone{
+			Name: "test.zone.",
+			RRsets: shared.RRsets{
+				{Name: "www.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.1"}}},
+				{Name: "old.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.2"}}},
+			},
+		}}})
+	}
+
+	desired := shared.RRsets{
+		{Name: "www.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.3"}}},
+	}
+
+	plan, err := s.pdnsCli.PlanZonePatch("test.zone.", desired)
+	c.Assert(err, IsNil)
+	c.Assert(len(plan), Equals, 2)
+	c.Assert(plan[0].ChangeType, Equals, authoritative.RRsetReplace)
+	c.Assert(plan[0].RRset.Equals(desired[0]), Equals, true)
+	c.Assert(plan[1].ChangeType, Equals, authoritative.RRSetDelete)
+	c.Assert(plan[1].Name, Equals, "old.test.zone.")
+}
+
+func (s *ClientSuite) TestPlanZonePatchNotFound(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(c, w, http.StatusNotFound, shared.Error{Message: "Could not find domain 'test.zone.'"})
+	}
+
+	_, err := s.pdnsCli.PlanZonePatch("test.zone.", shared.RRsets{})
+	c.Assert(IsNotFound(err), Equals, true)
+}
+
+func (s *ClientSuite) TestGetRRset(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/test.zone.")
+		c.Check(r.URL.Query().Get("rrset_name"), Matches, `(www|mail)\.test\.zone\.`)
+		c.Check(r.URL.Query().Get("rrset_type"), Equals, "A")
+		// Respond like a server which ignores the filter
+		writeJSON(c, w, http.StatusOK, authoritative.ZoneResponse{Zone: authoritative.Zone{Zone: shared.Zone{
+			Name: "test.zone.",
+			RRsets: shared.RRsets{
+				{Name: "www.test.zone.", Type: "AAAA", TTL: 300, Records: shared.Records{{Content: "2001:db8::1"}}},
+				{Name: "www.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.1"}}},
+			},
+		}}})
+	}
+
+	rrset, found, err := s.pdnsCli.GetRRset("test.zone.", "www.test.zone.", "A")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(rrset.Records, DeepEquals, shared.Records{{Content: "192.0.2.1"}})
+
+	_, found, err = s.pdnsCli.GetRRset("test.zone.", "mail.test.zone.", "A")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
+
+	// Names which are not fully qualified are canonicalized before the request and the comparison.
+	rrset, found, err = s.pdnsCli.GetRRset("test.zone", "www.test.zone", "A")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(rrset.Name, Equals, "www.test.zone.")
+}
+
+func (s *ClientSuite) TestUpsertAndDeleteRecord(c *C) {
+	var patch authoritative.PatchZoneRequest
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "PATCH")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/test.zone.")
+		patch = authoritative.PatchZoneRequest{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&patch), IsNil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	err := s.pdnsCli.UpsertRecord("test.zone", "_acme-challenge.test.zone", "TXT", 60, `"token1"`, `"token2"`)
+	c.Assert(err, IsNil)
+	c.Assert(patch.RRSets, DeepEquals, authoritative.PatchRRSets{{
+		RRset: shared.RRset{Name: "_acme-challenge.test.zone.", Type: "TXT", TTL: 60, Records: shared.Records{
+			{Content: `"token1"`}, {Content: `"token2"`},
+		}},
+		ChangeType: authoritative.RRsetReplace,
+	}})
+
+	// Unquoted TXT contents are quoted
+	err = s.pdnsCli.UpsertRecord("test.zone.", "_acme-challenge.test.zone.", "TXT", 60, "token 3")
+	c.Assert(err, IsNil)
+	c.Assert(patch.RRSets[0].Records, DeepEquals, shared.Records{{Content: `"token 3"`}})
+
+	err = s.pdnsCli.DeleteRecord("test.zone.", "_acme-challenge.test.zone.", "TXT")
+	c.Assert(err, IsNil)
+	c.Assert(len(patch.RRSets), Equals, 1)
+	c.Assert(patch.RRSets[0].UniqueName(), Equals, shared.RRsetUniqueName{Name: "_acme-challenge.test.zone.", Type: "TXT"})
+	c.Assert(patch.RRSets[0].ChangeType, Equals, authoritative.RRSetDelete)
+
+	c.Assert(s.pdnsCli.UpsertRecord("test.zone.", "www.test.zone.", "A", 60), Equals, ErrClientRecordContentEmpty)
+}
+
+func (s *ClientSuite) TestSetZoneKind(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "PUT")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/test.zone.")
+		body, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		// Only the kind may be sent, or other zone settings would be reset
+		c.Check(string(body), Equals, `{"kind":"Master"}`)
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	c.Assert(s.pdnsCli.SetZoneKind("test.zone.", authoritative.KindMaster), IsNil)
+	c.Assert(s.pdnsCli.SetZoneKind("test.zone.", "Primary"), Equals, ErrClientZoneKindInvalid)
+}
+
+func (s *ClientSuite) TestUpdateZoneMetadata(c *C) {
+	var body string
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "PUT")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/test.zone.")
+		raw, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		body = string(raw)
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	account := "customer-1"
+	c.Assert(s.pdnsCli.UpdateZoneMetadata("test.zone.", authoritative.ZoneUpdate{Account: &account}), IsNil)
+	c.Assert(body, Equals, `{"account":"customer-1"}`)
+
+	soaEdit := authoritative.SoaEditValueInceptionEpoch
+	dnssec := false
+	c.Assert(s.pdnsCli.UpdateZoneMetadata("test.zone.", authoritative.ZoneUpdate{
+		SoaEdit:    &soaEdit,
+		SoaEditAPI: &soaEdit,
+		DNSsec:     &dnssec,
+	}), IsNil)
+	c.Assert(body, Equals, `{"dnssec":false,"soa_edit":"INCEPTION-EPOCH","soa_edit_api":"INCEPTION-EPOCH"}`)
+}
+
+func (s *ClientSuite) TestSetNSEC3Param(c *C) {
+	var body string
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "PUT")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones/test.zone.")
+		raw, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		body = string(raw)
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	c.Assert(s.pdnsCli.SetNSEC3Param("test.zone.", "1 0 1 ab", true), IsNil)
+	c.Assert(body, Equals, `{"nsec3param":"1 0 1 ab","nsec3narrow":true}`)
+
+	// An empty param is still sent, to switch back to NSEC
+	c.Assert(s.pdnsCli.SetNSEC3Param("test.zone.", "", false), IsNil)
+	c.Assert(body, Equals, `{"nsec3param":"","nsec3narrow":false}`)
+}
+
+func (s *ClientSuite) TestRetrieveZoneAndWaitForSerial(c *C) {
+	serial := uint32(1)
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "PUT" && r.URL.Path == "/api/v1/servers/localhost/zones/slave.zone./axfr-retrieve":
+			writeJSON(c, w, http.StatusOK, shared.ActionResult{Result: "Added retrieval request for 'slave.zone.'"})
+		case r.Method == "GET" && r.URL.Path == "/api/v1/servers/localhost/zones/slave.zone.":
+			// The transfer lands after a few polls
+			writeJSON(c, w, http.StatusOK, authoritative.ZoneResponse{Serial: serial})
+			serial++
+		default:
+			c.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}
+
+	result, err := s.pdnsCli.RetrieveZone("slave.zone.")
+	c.Assert(err, IsNil)
+	c.Assert(result, Equals, "Added retrieval request for 'slave.zone.'")
+
+	reached, err := s.pdnsCli.WaitForSerial(context.Background(), "slave.zone.", 3, time.Millisecond)
+	c.Assert(err, IsNil)
+	c.Assert(reached, Equals, uint32(3))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	reached, err = s.pdnsCli.WaitForSerial(ctx, "slave.zone.", 1000000, time.Millisecond*10)
+	c.Assert(errwrap.Contains(err, ErrClientSerialWait.Error()), Equals, true)
+	c.Assert(reached >= 4, Equals, true)
+	c.Assert(reached < 1000000, Equals, true)
+
+	// Other errors are returned immediately
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(c, w, http.StatusNotFound, shared.Error{Message: "Could not find domain 'slave.zone.'"})
+	}
+	_, err = s.pdnsCli.WaitForSerial(context.Background(), "slave.zone.", 3, time.Millisecond)
+	c.Assert(IsNotFound(err), Equals, true)
+}
+
+func (s *ClientSuite) TestAutoprimaries(c *C) {
+	autoprimary := authoritative.Autoprimary{IP: "2001:db8::1", Nameserver: "ns1.example.com.", Account: "ops"}
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/autoprimaries")
+			writeJSON(c, w, http.StatusOK, []authoritative.Autoprimary{autoprimary})
+		case "POST":
+			c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/autoprimaries")
+			req := authoritative.Autoprimary{}
+			c.Assert(json.NewDecoder(r.Body).Decode(&req), IsNil)
+			c.Check(req, Equals, autoprimary)
+			w.WriteHeader(http.StatusCreated)
+		case "DELETE":
+			c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/autoprimaries/2001:db8::1/ns1.example.com.")
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+
+	autoprimaries, err := s.pdnsCli.ListAutoprimaries()
+	c.Assert(err, IsNil)
+	c.Assert(autoprimaries, DeepEquals, []authoritative.Autoprimary{autoprimary})
+
+	c.Assert(s.pdnsCli.CreateAutoprimary(autoprimary), IsNil)
+	c.Assert(s.pdnsCli.DeleteAutoprimary(autoprimary.IP, autoprimary.Nameserver), IsNil)
+}
+
+func (s *ClientSuite) TestPing(c *C) {
+	status := http.StatusOK
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost")
+		c.Check(r.Header.Get("X-API-Key"), Equals, testAPIKey)
+		writeJSON(c, w, status, shared.ServerInfo{ID: "localhost"})
+	}
+
+	c.Assert(s.pdnsCli.Ping(context.Background()), IsNil)
+
+	status = http.StatusServiceUnavailable
+	err := s.pdnsCli.Ping(context.Background())
+	statusCode, ok := StatusCode(err)
+	c.Assert(ok, Equals, true)
+	c.Assert(statusCode, Equals, http.StatusServiceUnavailable)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Assert(s.pdnsCli.Ping(ctx), NotNil)
+}
+
+func (s *ClientSuite) TestPresentAndCleanupTXT(c *C) {
+	server := powerdnstest.NewServer()
+	defer server.Close()
+	pdnsCli, err := NewClient(server.URL, testAPIKey, false, 0)
+	c.Assert(err, IsNil)
+
+	_, err = pdnsCli.CreateZone(authoritative.ZoneRequestNative{
+		Zone:        authoritative.Zone{Zone: shared.Zone{Name: "acme.zone."}, Kind: authoritative.KindNative},
+		Nameservers: []string{"ns1.acme.zone."},
+	})
+	c.Assert(err, IsNil)
+
+	txtRecords := func() shared.Records {
+		rrset, _, err := pdnsCli.GetRRset("acme.zone.", "_acme-challenge.acme.zone.", "TXT")
+		c.Assert(err, IsNil)
+		rrset.Records.Sort()
+		return rrset.Records
+	}
+
+	const fqdn = "_acme-challenge.acme.zone"
+	c.Assert(pdnsCli.PresentTXT("acme.zone", fqdn, "token-a", 60), IsNil)
+	c.Assert(pdnsCli.PresentTXT("acme.zone", fqdn, "token-b", 60), IsNil)
+	// Presenting again is idempotent.
+	c.Assert(pdnsCli.PresentTXT("acme.zone", fqdn, "token-a", 60), IsNil)
+	c.Assert(txtRecords(), DeepEquals, shared.Records{{Content: `"token-a"`}, {Content: `"token-b"`}})
+
+	c.Assert(pdnsCli.CleanupTXT("acme.zone", fqdn, "token-a"), IsNil)
+	c.Assert(txtRecords(), DeepEquals, shared.Records{{Content: `"token-b"`}})
+	// Cleaning up a value which is gone is not an error.
+	c.Assert(pdnsCli.CleanupTXT("acme.zone", fqdn, "token-a"), IsNil)
+
+	c.Assert(pdnsCli.CleanupTXT("acme.zone", fqdn, "token-b"), IsNil)
+	_, found, err := pdnsCli.GetRRset("acme.zone.", "_acme-challenge.acme.zone.", "TXT")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
+	c.Assert(pdnsCli.CleanupTXT("acme.zone", fqdn, "token-b"), IsNil)
+}
+
+func (s *ClientSuite) TestUpsertRecordWithPTR(c *C) {
+	server := powerdnstest.NewServer()
+	defer server.Close()
+	pdnsCli, err := NewClient(server.URL, testAPIKey, false, 0)
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"ptr.zone.", "2.0.192.in-addr.arpa."} {
+		_, err = pdnsCli.CreateZone(authoritative.ZoneRequestNative{
+			Zone:        authoritative.Zone{Zone: shared.Zone{Name: name}, Kind: authoritative.KindNative},
+			Nameservers: []string{"ns1.ptr.zone."},
+		})
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(pdnsCli.UpsertRecordWithPTR("ptr.zone", "www.ptr.zone", "A", 300, "192.0.2.1"), IsNil)
+	rrset, found, err := pdnsCli.GetRRset("ptr.zone.", "www.ptr.zone.", "A")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(rrset.Records, DeepEquals, shared.Records{{Content: "192.0.2.1", SetPtr: true}})
+
+	// There is no reverse zone for 198.51.100.0/24 or any IPv6 address.
+	err = pdnsCli.UpsertRecordWithPTR("ptr.zone", "www.ptr.zone", "A", 300, "198.51.100.1")
+	c.Assert(errwrap.Contains(err, ErrClientReverseZoneMissing.Error()), Equals, true)
+	c.Assert(errwrap.Contains(err, "1.100.51.198.in-addr.arpa."), Equals, true)
+	err = pdnsCli.UpsertRecordWithPTR("ptr.zone", "www.ptr.zone", "AAAA", 300, "2001:db8::1")
+	c.Assert(errwrap.Contains(err, ErrClientReverseZoneMissing.Error()), Equals, true)
+	c.Assert(errwrap.Contains(err,
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."), Equals, true)
+
+	// The address must match the record type.
+	c.Assert(pdnsCli.UpsertRecordWithPTR("ptr.zone", "www.ptr.zone", "AAAA", 300, "192.0.2.1"),
+		Equals, ErrClientPTRAddressInvalid)
+	c.Assert(pdnsCli.UpsertRecordWithPTR("ptr.zone", "www.ptr.zone", "A", 300, "2001:db8::1"),
+		Equals, ErrClientPTRAddressInvalid)
+	c.Assert(pdnsCli.UpsertRecordWithPTR("ptr.zone", "www.ptr.zone", "CNAME", 300, "192.0.2.1"),
+		Equals, ErrClientPTRAddressInvalid)
+}
+
+func (s *ClientSuite) TestUpsertRecordWithPTRZoneLookup(c *C) {
+	lookups := []string{}
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			c.Check(r.URL.Path, Equals, "/api/v1/servers/localhost/zones")
+			filter := r.URL.Query().Get("zone")
+			c.Check(filter, Not(Equals), "")
+			lookups = append(lookups, filter)
+			zones := []authoritative.ZoneResponse{}
+			if filter == "51.198.in-addr.arpa." {
+				zones = append(zones, authoritative.ZoneResponse{Zone: authoritative.Zone{Zone: shared.Zone{Name: filter}}})
+			}
+			writeJSON(c, w, http.StatusOK, zones)
+		case "PATCH":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			c.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}
+
+	// The PTR name's parents are looked up nearest first, stopping at the first zone found.
+	c.Assert(s.pdnsCli.UpsertRecordWithPTR("ptr.zone", "www.ptr.zone", "A", 300, "198.51.100.1"), IsNil)
+	c.Assert(lookups, DeepEquals, []string{
+		"1.100.51.198.in-addr.arpa.", "100.51.198.in-addr.arpa.", "51.198.in-addr.arpa.",
+	})
+
+	// Without a reverse zone the lookups end at in-addr.arpa.
+	lookups = []string{}
+	err := s.pdnsCli.UpsertRecordWithPTR("ptr.zone", "www.ptr.zone", "A", 300, "192.0.2.1")
+	c.Assert(errwrap.Contains(err, ErrClientReverseZoneMissing.Error()), Equals, true)
+	c.Assert(lookups, DeepEquals, []string{
+		"1.2.0.192.in-addr.arpa.", "2.0.192.in-addr.arpa.", "0.192.in-addr.arpa.", "192.in-addr.arpa.",
+		"in-addr.arpa.",
+	})
+}
+
+func (s *ClientSuite) TestDeleteRecordsOfType(c *C) {
+	server := powerdnstest.NewServer()
+	defer server.Close()
+	pdnsCli, err := NewClient(server.URL, testAPIKey, false, 0)
+	c.Assert(err, IsNil)
+
+	_, err = pdnsCli.CreateZone(authoritative.ZoneRequestNative{
+		Zone:        authoritative.Zone{Zone: shared.Zone{Name: "cleanup.zone."}, Kind: authoritative.KindNative},
+		Nameservers: []string{"ns1.cleanup.zone."},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(pdnsCli.UpsertRecord("cleanup.zone", "cleanup.zone", "TXT", 300, "legacy"), IsNil)
+	c.Assert(pdnsCli.UpsertRecord("cleanup.zone", "old.cleanup.zone", "TXT", 300, "legacy"), IsNil)
+	c.Assert(pdnsCli.UpsertRecord("cleanup.zone", "www.cleanup.zone", "A", 300, "192.0.2.1"), IsNil)
+
+	c.Assert(pdnsCli.DeleteRecordsOfType("cleanup.zone", "txt"), IsNil)
+	zone, err := pdnsCli.GetZone("cleanup.zone.")
+	c.Assert(err, IsNil)
+	c.Assert(zone.RRsets.FilterByType("TXT"), HasLen, 0)
+	c.Assert(zone.RRsets.FilterByType("A"), HasLen, 1)
+	c.Assert(zone.RRsets.FilterByType("SOA", "NS"), HasLen, 2)
+
+	// Nothing is changed, so the serial is not bumped, when there are no RRsets of the type.
+	c.Assert(pdnsCli.DeleteRecordsOfType("cleanup.zone", "TXT"), IsNil)
+	unchanged, err := pdnsCli.GetZone("cleanup.zone.")
+	c.Assert(err, IsNil)
+	c.Assert(unchanged.Serial, Equals, zone.Serial)
+
+	c.Assert(IsNotFound(pdnsCli.DeleteRecordsOfType("missing.zone", "TXT")), Equals, true)
+}
+
+func (s *ClientSuite) TestExportAndImportAll(c *C) {
+	// The source server fails every request for broken.zone., to check the rest of the export carries on.
+	handler := powerdnstest.NewHandler()
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/zones/broken.zone.") {
+			writeJSON(c, w, http.StatusInternalServerError, shared.Error{Message: "Backend failure"})
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer source.Close()
+	sourceCli, err := NewClient(source.URL, testAPIKey, false, 0)
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"snap.zone.", "broken.zone."} {
+		_, err = sourceCli.CreateZone(authoritative.ZoneRequestNative{
+			Zone:        authoritative.Zone{Zone: shared.Zone{Name: name}, Kind: authoritative.KindNative},
+			Nameservers: []string{"ns1.snap.zone."},
+		})
+		c.Assert(err, IsNil)
+	}
+	_, err = sourceCli.CreateSlaveZone(authoritative.ZoneRequestSlave{
+		Zone:    authoritative.Zone{Zone: shared.Zone{Name: "secondary.zone."}, Kind: authoritative.KindSlave},
+		Masters: []string{"192.0.2.10", "[2001:db8::10]:5300"},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(sourceCli.UpsertRecord("snap.zone.", "www.snap.zone.", "A", 300, "192.0.2.1", "192.0.2.2"), IsNil)
+	c.Assert(sourceCli.DoRequest("zones/snap.zone./cryptokeys", "POST",
+		&authoritative.Cryptokey{KeyType: "csk", Active: true}, nil), IsNil)
+	c.Assert(sourceCli.SetNSEC3Param("snap.zone.", "1 0 0 -", false), IsNil)
+	c.Assert(sourceCli.DoRequest("zones/snap.zone./metadata/ALSO-NOTIFY", "PUT",
+		&authoritative.Metadata{Kind: "ALSO-NOTIFY", Metadata: []string{"192.0.2.53"}}, nil), IsNil)
+	_, err = sourceCli.CreateTSIGKey(authoritative.TSIGKey{Name: "transfer", Algorithm: "hmac-sha256"})
+	c.Assert(err, IsNil)
+
+	snapshot, err := sourceCli.ExportAll(context.Background())
+	snapshotErr, ok := err.(ErrSnapshot)
+	c.Assert(ok, Equals, true, Commentf("%v", err))
+	c.Assert(snapshotErr.Zones, HasLen, 1)
+	c.Assert(snapshotErr.TSIGKeys, HasLen, 0)
+	c.Assert(errwrap.Contains(snapshotErr.Zones["broken.zone."], "Backend failure"), Equals, true)
+	c.Assert(snapshot.Zones, HasLen, 2)
+	c.Assert(snapshot.TSIGKeys, HasLen, 1)
+	c.Assert(snapshot.TSIGKeys[0].Key, Not(Equals), "")
+
+	snapZone := snapshot.Zones[1]
+	c.Assert(snapZone.Zone.Name, Equals, "snap.zone.")
+	c.Assert(snapZone.Cryptokeys, HasLen, 1)
+	c.Assert(snapZone.Cryptokeys[0].PrivateKey, Not(Equals), "")
+
+	// The snapshot survives serialization.
+	data, err := json.Marshal(snapshot)
+	c.Assert(err, IsNil)
+	restored := Snapshot{}
+	c.Assert(json.Unmarshal(data, &restored), IsNil)
+
+	target := powerdnstest.NewServer()
+	defer target.Close()
+	targetCli, err := NewClient(target.URL, testAPIKey, false, 0)
+	c.Assert(err, IsNil)
+
+	// Importing twice must not duplicate anything, since imports are resumed by running them again.
+	c.Assert(targetCli.ImportAll(context.Background(), restored), IsNil)
+	c.Assert(targetCli.UpsertRecord("snap.zone.", "stale.snap.zone.", "A", 300, "192.0.2.3"), IsNil)
+	c.Assert(targetCli.ImportAll(context.Background(), restored), IsNil)
+
+	imported, err := targetCli.ExportAll(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(imported.TSIGKeys, DeepEquals, snapshot.TSIGKeys)
+	c.Assert(imported.Zones, HasLen, 2)
+	c.Assert(imported.Zones[0].Zone.Name, Equals, "secondary.zone.")
+	c.Assert(imported.Zones[0].Zone.Kind, Equals, authoritative.KindSlave)
+	c.Assert(snapshot.Zones[0].Zone.Masters, DeepEquals, []string{"192.0.2.10", "[2001:db8::10]:5300"})
+	c.Assert(imported.Zones[0].Zone.Masters, DeepEquals, snapshot.Zones[0].Zone.Masters)
+
+	importedZone := imported.Zones[1]
+	c.Assert(importedZone.Zone.Equals(snapZone.Zone.Zone), Equals, true)
+	c.Assert(importedZone.Zone.HeaderEquals(snapZone.Zone.Zone), Equals, true)
+	c.Assert(importedZone.Zone.NSEC3Param, Equals, "1 0 0 -")
+	c.Assert(importedZone.Metadata, DeepEquals, snapZone.Metadata)
+	c.Assert(importedZone.Cryptokeys, HasLen, 1)
+	c.Assert(importedZone.Cryptokeys[0].DNSKey, Equals, snapZone.Cryptokeys[0].DNSKey)
+	c.Assert(importedZone.Cryptokeys[0].Active, Equals, true)
+}
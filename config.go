@@ -0,0 +1,37 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+const (
+	configPathString = "config"
+)
+
+// Config returns all configuration settings of the server.
+func (p *Client) Config() ([]shared.ConfigSetting, error) {
+	return p.ConfigContext(context.Background())
+}
+
+// ConfigContext is like Config but uses ctx for the requests it makes.
+func (p *Client) ConfigContext(ctx context.Context) ([]shared.ConfigSetting, error) {
+	settings := []shared.ConfigSetting{}
+	err := p.DoRequestContext(ctx, configPathString, "GET", nil, &settings)
+	return settings, err
+}
+
+// ConfigSetting returns the named configuration setting of the server.
+func (p *Client) ConfigSetting(name string) (shared.ConfigSetting, error) {
+	return p.ConfigSettingContext(context.Background(), name)
+}
+
+// ConfigSettingContext is like ConfigSetting but uses ctx for the requests it makes.
+func (p *Client) ConfigSettingContext(ctx context.Context, name string) (shared.ConfigSetting, error) {
+	setting := shared.ConfigSetting{}
+	err := p.DoRequestContext(ctx, fmt.Sprintf("%s/%s", configPathString, url.PathEscape(name)), "GET", nil, &setting)
+	return setting, err
+}
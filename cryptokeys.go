@@ -0,0 +1,33 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+)
+
+// cryptokeyPath returns the API sub-path of a cryptokey in the named zone.
+func cryptokeyPath(zone string, id int) string {
+	return fmt.Sprintf("%s/cryptokeys/%d", zonePath(zone), id)
+}
+
+// SetCryptoKeyActive activates or deactivates a cryptokey of the named zone. ErrClientZoneNotDNSSEC is returned if
+// the zone does not have DNSSEC enabled.
+func (p *Client) SetCryptoKeyActive(zone string, id int, active bool) error {
+	return p.SetCryptoKeyActiveContext(context.Background(), zone, id, active)
+}
+
+// SetCryptoKeyActiveContext is like SetCryptoKeyActive but uses ctx for the requests it makes.
+func (p *Client) SetCryptoKeyActiveContext(ctx context.Context, zone string, id int, active bool) error {
+	zoneResponse, err := p.GetZoneContext(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	if !zoneResponse.DNSsec {
+		return ErrClientZoneNotDNSSEC
+	}
+
+	return p.DoRequestContext(ctx, cryptokeyPath(zone, id), "PUT", &authoritative.Cryptokey{Active: active}, nil)
+}
@@ -0,0 +1,119 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+// Feature is an API feature which is only available from some version of the PowerDNS authoritative server.
+type Feature int
+
+const (
+	// FeatureSearchData is the search-data endpoint used by Search.
+	FeatureSearchData Feature = iota
+	// FeatureAutoprimaries is the autoprimaries endpoint.
+	FeatureAutoprimaries
+	// FeatureRRsetsFalse is listing zones without their RRsets (rrsets=false).
+	FeatureRRsetsFalse
+	// FeatureRectify is the zone rectify endpoint.
+	FeatureRectify
+)
+
+func (f Feature) String() string {
+	switch f {
+	case FeatureSearchData:
+		return "search-data"
+	case FeatureAutoprimaries:
+		return "autoprimaries"
+	case FeatureRRsetsFalse:
+		return "rrsets=false"
+	case FeatureRectify:
+		return "rectify"
+	default:
+		return fmt.Sprintf("Feature(%d)", int(f))
+	}
+}
+
+// featureMinVersions maps features to the first authoritative server version which supports them.
+var featureMinVersions = map[Feature][3]int{
+	FeatureSearchData:    {4, 0, 0},
+	FeatureAutoprimaries: {4, 5, 0},
+	// The rrsets query parameter was added in 4.2.0, see https://doc.powerdns.com/authoritative/changelog/4.2.html.
+	// Older servers ignore it, so ListZonesFiltered does not require it.
+	FeatureRRsetsFalse: {4, 2, 0},
+	FeatureRectify:     {4, 1, 0},
+}
+
+// ErrUnsupportedFeature is returned when daemon type checking is enabled and a method needs a feature the server's
+// version does not support.
+type ErrUnsupportedFeature struct {
+	Feature Feature
+	Version string
+}
+
+func (err ErrUnsupportedFeature) Error() string {
+	return fmt.Sprintf("This PowerDNS version (%s) does not support %s", err.Version, err.Feature)
+}
+
+// Supports returns whether the server supports feature. The server info is cached after the first successful
+// lookup. Only authoritative servers support any features.
+func (p *Client) Supports(feature Feature) (bool, error) {
+	return p.SupportsContext(context.Background(), feature)
+}
+
+// SupportsContext is like Supports but uses ctx for the requests it makes.
+func (p *Client) SupportsContext(ctx context.Context, feature Feature) (bool, error) {
+	minVersion, found := featureMinVersions[feature]
+	if !found {
+		return false, nil
+	}
+
+	server, err := p.cachedServerInfo(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if server.DaemonType != shared.DaemonTypeAuthoritative {
+		return false, nil
+	}
+
+	major, minor, patch, err := server.SemVer()
+	if err != nil {
+		return false, err
+	}
+
+	for idx, version := range [3]int{major, minor, patch} {
+		if version != minVersion[idx] {
+			return version > minVersion[idx], nil
+		}
+	}
+	return true, nil
+}
+
+// requireFeature returns ErrUnsupportedFeature if daemon type checking is enabled and the server does not support
+// feature.
+func (p *Client) requireFeature(ctx context.Context, feature Feature) error {
+	if !p.checkDaemonType {
+		return nil
+	}
+
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeAuthoritative); err != nil {
+		return err
+	}
+
+	supported, err := p.SupportsContext(ctx, feature)
+	if err != nil {
+		return err
+	}
+
+	if !supported {
+		server, err := p.cachedServerInfo(ctx)
+		if err != nil {
+			return err
+		}
+		return ErrUnsupportedFeature{Feature: feature, Version: server.Version}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package powerdns
+
+import (
+	"strings"
+
+	"github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+// nameContentTypes are the record types whose content ends with a domain name, which WithAutoFQDN makes fully
+// qualified.
+var nameContentTypes = map[string]bool{
+	"CNAME": true,
+	"MX":    true,
+	"NS":    true,
+	"PTR":   true,
+	"SRV":   true,
+}
+
+// fqdnContent makes the domain name at the end of content fully qualified, if records of rrtype end with one.
+func fqdnContent(rrtype, content string) string {
+	if !nameContentTypes[strings.ToUpper(rrtype)] || content == "" || strings.HasSuffix(content, ".") {
+		return content
+	}
+	return content + "."
+}
+
+// fqdnRRsets returns copies of rrsets with their names, and the names in their record contents, made fully
+// qualified.
+func fqdnRRsets(rrsets shared.RRsets) shared.RRsets {
+	if rrsets == nil {
+		return nil
+	}
+
+	result := rrsets.Copy()
+	for idx := range result {
+		rrset := &result[idx]
+		rrset.Name = canonicalName(rrset.Name)
+		// Copying turns nil records into an empty array, which a REPLACE would take as deleting them all, e.g. for
+		// SetRRsetComments.
+		if rrsets[idx].Records == nil {
+			rrset.Records = nil
+		}
+		for ridx := range rrset.Records {
+			rrset.Records[ridx].Content = fqdnContent(rrset.Type, rrset.Records[ridx].Content)
+		}
+	}
+	return result
+}
+
+// fqdnPatchRRSets is like fqdnRRsets for the changes of a zone patch.
+func fqdnPatchRRSets(changes authoritative.PatchRRSets) authoritative.PatchRRSets {
+	rrsets := make(shared.RRsets, 0, len(changes))
+	for _, change := range changes {
+		rrsets = append(rrsets, change.RRset)
+	}
+	rrsets = fqdnRRsets(rrsets)
+
+	result := make(authoritative.PatchRRSets, 0, len(changes))
+	for idx, change := range changes {
+		result = append(result, authoritative.PatchRRSet{RRset: rrsets[idx], ChangeType: change.ChangeType})
+	}
+	return result
+}
+
+// fqdnNames returns a copy of names with each made fully qualified.
+func fqdnNames(names []string) []string {
+	if names == nil {
+		return nil
+	}
+
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		result = append(result, canonicalName(name))
+	}
+	return result
+}
@@ -0,0 +1,43 @@
+// Package errs wraps errors so that both errwrap and the standard library's errors.Is and errors.As can inspect
+// them.
+package errs
+
+import (
+	"errors"
+)
+
+// Wrap is a drop-in replacement for errwrap.Wrap. The result's message is outer's, and errwrap.Contains,
+// errwrap.GetType and the like see both outer and inner as before. In addition errors.Is and errors.As match outer
+// and then, by unwrapping, inner.
+func Wrap(outer, inner error) error {
+	return &wrappedError{outer: outer, inner: inner}
+}
+
+type wrappedError struct {
+	outer error
+	inner error
+}
+
+func (w *wrappedError) Error() string {
+	return w.outer.Error()
+}
+
+// WrappedErrors implements errwrap.Wrapper
+func (w *wrappedError) WrappedErrors() []error {
+	return []error{w.outer, w.inner}
+}
+
+// Unwrap returns the inner error, for errors.Is and errors.As.
+func (w *wrappedError) Unwrap() error {
+	return w.inner
+}
+
+// Is reports whether outer matches target, for errors.Is.
+func (w *wrappedError) Is(target error) bool {
+	return errors.Is(w.outer, target)
+}
+
+// As finds the first error in outer's chain which matches target, for errors.As.
+func (w *wrappedError) As(target interface{}) bool {
+	return errors.As(w.outer, target)
+}
@@ -0,0 +1,156 @@
+package powerdns
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpointClass returns the class of endpoint a request sub-path addresses, for labelling metrics. Requests for a
+// zone's sub-resources (e.g. "zones/example.com./cryptokeys/1") are classed by the sub-resource, and everything
+// else by the first path segment, so zone names and IDs never appear in the result.
+func endpointClass(subPath *url.URL) string {
+	segments := strings.Split(strings.Trim(subPath.Path, "/"), "/")
+	switch {
+	case segments[0] == "":
+		return "root"
+	case segments[0] == zonesPathString && len(segments) > 2:
+		return segments[2]
+	default:
+		return segments[0]
+	}
+}
+
+// DefaultDurationBuckets are the upper bounds, in seconds, of the request duration histogram buckets used by
+// NewPrometheusMetrics. They match the Prometheus client library's default buckets.
+var DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics is a MetricsObserver which maintains Prometheus metrics for the requests a Client sends:
+//
+//	<namespace>_requests_total{method, endpoint, code}            counter
+//	<namespace>_request_duration_seconds{method, endpoint}        histogram
+//
+// code is the HTTP status code, or "0" if no response was received. PrometheusMetrics is an http.Handler which
+// serves the metrics in the Prometheus text exposition format, so it can be scraped directly or mounted beside an
+// application's own metrics. It is safe for concurrent use.
+type PrometheusMetrics struct {
+	namespace string
+	buckets   []float64
+
+	mtx       sync.Mutex
+	requests  map[requestLabels]uint64
+	durations map[durationLabels]*durationHistogram
+}
+
+type requestLabels struct {
+	method   string
+	endpoint string
+	code     string
+}
+
+type durationLabels struct {
+	method   string
+	endpoint string
+}
+
+type durationHistogram struct {
+	counts []uint64 // counts[i] is the number of observations <= buckets[i], i.e. already cumulative.
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheusMetrics returns a PrometheusMetrics whose metric names are prefixed by namespace, e.g.
+// "powerdns_client". Pass it to WithMetrics to record a Client's requests.
+func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
+	return &PrometheusMetrics{
+		namespace: namespace,
+		buckets:   DefaultDurationBuckets,
+		requests:  make(map[requestLabels]uint64),
+		durations: make(map[durationLabels]*durationHistogram),
+	}
+}
+
+// ObserveRequest implements MetricsObserver.
+func (m *PrometheusMetrics) ObserveRequest(method, endpointClass string, status int, dur time.Duration, err error) {
+	seconds := dur.Seconds()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.requests[requestLabels{method, endpointClass, strconv.Itoa(status)}]++
+
+	key := durationLabels{method, endpointClass}
+	hist, found := m.durations[key]
+	if !found {
+		hist = &durationHistogram{counts: make([]uint64, len(m.buckets))}
+		m.durations[key] = hist
+	}
+	for i, upper := range m.buckets {
+		if seconds <= upper {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += seconds
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	requestsName := m.namespace + "_requests_total"
+	fmt.Fprintf(w, "# HELP %s Requests sent to the PowerDNS API.\n", requestsName)
+	fmt.Fprintf(w, "# TYPE %s counter\n", requestsName)
+	requestKeys := make([]requestLabels, 0, len(m.requests))
+	for key := range m.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		a, b := requestKeys[i], requestKeys[j]
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		if a.endpoint != b.endpoint {
+			return a.endpoint < b.endpoint
+		}
+		return a.code < b.code
+	})
+	for _, key := range requestKeys {
+		fmt.Fprintf(w, "%s{method=%q,endpoint=%q,code=%q} %d\n",
+			requestsName, key.method, key.endpoint, key.code, m.requests[key])
+	}
+
+	durationName := m.namespace + "_request_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Latency of requests sent to the PowerDNS API.\n", durationName)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", durationName)
+	durationKeys := make([]durationLabels, 0, len(m.durations))
+	for key := range m.durations {
+		durationKeys = append(durationKeys, key)
+	}
+	sort.Slice(durationKeys, func(i, j int) bool {
+		a, b := durationKeys[i], durationKeys[j]
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.endpoint < b.endpoint
+	})
+	for _, key := range durationKeys {
+		hist := m.durations[key]
+		labels := fmt.Sprintf("method=%q,endpoint=%q", key.method, key.endpoint)
+		for i, upper := range m.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n",
+				durationName, labels, strconv.FormatFloat(upper, 'g', -1, 64), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", durationName, labels, hist.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", durationName, labels, strconv.FormatFloat(hist.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", durationName, labels, hist.count)
+	}
+}
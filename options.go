@@ -0,0 +1,274 @@
+package powerdns
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultServer       = "localhost"
+	defaultTimeout      = time.Second * 30
+	defaultAPIKeyHeader = "X-API-Key"
+)
+
+// defaultUserAgent returns the User-Agent sent unless WithUserAgent is used.
+func defaultUserAgent() string {
+	return "go.powerdns/" + libraryVersion()
+}
+
+// clientOptions collects the settings applied by ClientOptions.
+type clientOptions struct {
+	httpClient       *http.Client
+	transport        http.RoundTripper
+	proxyURL         *url.URL
+	tlsConfig        *tls.Config
+	server           string
+	timeout          time.Duration
+	keepAlives       bool
+	logger           Logger
+	trace            io.Writer
+	metrics          MetricsObserver
+	tracer           Tracer
+	limiter          *rate.Limiter
+	apiPath          string
+	apiKeyHeader     string
+	bearerToken      string
+	maxResponseBytes int64
+	userAgent        string
+	responseHook     ResponseHook
+	breakerFailures  int
+	breakerCooldown  time.Duration
+	autoFQDN         bool
+}
+
+// ClientOption configures a Client constructed with NewClientWithOptions.
+type ClientOption func(o *clientOptions)
+
+// WithHTTPClient uses the given http.Client for all requests. The transport options (WithProxy, WithTLSConfig
+// and WithKeepAlives) have no effect when this is set, since the http.Client is used as-is.
+func WithHTTPClient(cli *http.Client) ClientOption {
+	return func(o *clientOptions) {
+		o.httpClient = cli
+	}
+}
+
+// WithTransport sends all requests with the given http.RoundTripper, e.g. a powerdnstest.Recorder or Replayer.
+// Like the other transport options it has no effect when WithHTTPClient is used, and WithProxy, WithTLSConfig and
+// WithKeepAlives have no effect when it is set.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(o *clientOptions) {
+		o.transport = transport
+	}
+}
+
+// WithProxy sends all requests through the given proxy.
+func WithProxy(proxyURL *url.URL) ClientOption {
+	return func(o *clientOptions) {
+		o.proxyURL = proxyURL
+	}
+}
+
+// WithTLSConfig uses the given TLS configuration, e.g. to trust a private CA or present a client certificate.
+func WithTLSConfig(tlsConfig *tls.Config) ClientOption {
+	return func(o *clientOptions) {
+		o.tlsConfig = tlsConfig
+	}
+}
+
+// WithServer addresses the named server instead of "localhost".
+func WithServer(server string) ClientOption {
+	return func(o *clientOptions) {
+		o.server = server
+	}
+}
+
+// WithTimeout sets the time allowed for each request, including reading the response. The default is 30 seconds.
+// Unlike the other transport options this also applies when WithHTTPClient is used.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithKeepAlives enables or disables reuse of connections between requests. Keepalives are disabled by default,
+// but enabling them greatly improves throughput for bulk operations.
+func WithKeepAlives(keepAlives bool) ClientOption {
+	return func(o *clientOptions) {
+		o.keepAlives = keepAlives
+	}
+}
+
+// WithLogger reports every request to logger.
+func WithLogger(logger Logger) ClientOption {
+	return func(o *clientOptions) {
+		o.logger = logger
+	}
+}
+
+// WithRequestTracing writes every request, and the response to it, to w for debugging. The API key is redacted.
+// Responses are written as they are read, so the traces of concurrent requests may be interleaved.
+func WithRequestTracing(w io.Writer) ClientOption {
+	return func(o *clientOptions) {
+		o.trace = w
+	}
+}
+
+// WithMetrics reports the outcome and latency of every request to observer. Use a PrometheusMetrics from
+// NewPrometheusMetrics to maintain Prometheus counters and histograms labelled by method and endpoint class.
+func WithMetrics(observer MetricsObserver) ClientOption {
+	return func(o *clientOptions) {
+		o.metrics = observer
+	}
+}
+
+// WithTracer wraps every request in a span started by tracer, as a child of any span in the request's context.
+func WithTracer(tracer Tracer) ClientOption {
+	return func(o *clientOptions) {
+		o.tracer = tracer
+	}
+}
+
+// WithRateLimiter waits for limiter before sending each request, to avoid overloading the server during bulk
+// operations. The limiter may be shared with other clients to limit their combined rate.
+func WithRateLimiter(limiter *rate.Limiter) ClientOption {
+	return func(o *clientOptions) {
+		o.limiter = limiter
+	}
+}
+
+// WithAPIPath sets the path of the API relative to the endpoint, instead of "api/v1/". This is needed when a
+// gateway in front of the server rewrites paths. A path starting with "/" replaces any path in the endpoint.
+func WithAPIPath(apiPath string) ClientOption {
+	return func(o *clientOptions) {
+		o.apiPath = apiPath
+	}
+}
+
+// WithAPIKeyHeader sends the API key in the named header instead of X-API-Key.
+func WithAPIKeyHeader(header string) ClientOption {
+	return func(o *clientOptions) {
+		o.apiKeyHeader = header
+	}
+}
+
+// WithBearerToken sends token in an "Authorization: Bearer" header, as expected by some gateways in front of the
+// server. The API key is then only sent if it is not empty.
+func WithBearerToken(token string) ClientOption {
+	return func(o *clientOptions) {
+		o.bearerToken = token
+	}
+}
+
+// WithMaxResponseBytes fails requests with ErrClientResponseTooLarge if the server's response body is larger than
+// n bytes, rather than reading it all into memory.
+func WithMaxResponseBytes(n int64) ClientOption {
+	return func(o *clientOptions) {
+		o.maxResponseBytes = n
+	}
+}
+
+// WithUserAgent sends userAgent in the User-Agent header of every request, so the client can be identified in
+// the server's logs. The default is "go.powerdns/<version>".
+func WithUserAgent(userAgent string) ClientOption {
+	return func(o *clientOptions) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithResponseHook calls hook with every response, whatever its status, before the body is read. This allows
+// headers such as rate limit or deprecation warnings from a gateway or the server to be inspected.
+func WithResponseHook(hook ResponseHook) ClientOption {
+	return func(o *clientOptions) {
+		o.responseHook = hook
+	}
+}
+
+// WithCircuitBreaker stops sending requests once failures consecutive requests have failed, returning
+// ErrCircuitOpen instead until cooldown has passed. A single request is then sent to probe the server, which
+// closes the breaker if it succeeds. Only errors sending requests and 429 or 5xx responses count as failures.
+func WithCircuitBreaker(failures int, cooldown time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.breakerFailures = failures
+		o.breakerCooldown = cooldown
+	}
+}
+
+// WithAutoFQDN makes zone names, RRset names and nameservers fully qualified in the zone create and patch methods,
+// so the trailing dot can be left off. The content of CNAME, MX, NS, PTR and SRV records, which ends with a name, is
+// made fully qualified too; other record contents are sent as given.
+func WithAutoFQDN() ClientOption {
+	return func(o *clientOptions) {
+		o.autoFQDN = true
+	}
+}
+
+// NewClientWithOptions initializes an API client using the given API key, configured by opts.
+func NewClientWithOptions(endpoint string, apiKey string, opts ...ClientOption) (*Client, error) {
+	options := clientOptions{
+		server:       defaultServer,
+		timeout:      defaultTimeout,
+		apiPath:      apiPathString,
+		apiKeyHeader: defaultAPIKeyHeader,
+		userAgent:    defaultUserAgent(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	client := options.httpClient
+	if client == nil {
+		tr := options.transport
+		if tr == nil {
+			tr = deadlineRoundTripper(options.timeout, options.proxyURL, options.tlsConfig, options.keepAlives)
+		}
+		client = &http.Client{Transport: tr}
+	}
+
+	// Decode the url
+	decodedURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set credentials
+	headers := http.Header{}
+	if apiKey != "" || options.bearerToken == "" {
+		headers[options.apiKeyHeader] = []string{apiKey}
+	}
+	if options.bearerToken != "" {
+		headers["Authorization"] = []string{"Bearer " + options.bearerToken}
+	}
+	if options.userAgent != "" {
+		headers["User-Agent"] = []string{options.userAgent}
+	}
+
+	apiClient, err := New(decodedURL, options.server, client, headers)
+	if err != nil {
+		return nil, err
+	}
+	apiClient.timeout = options.timeout
+	apiClient.logger = options.logger
+	apiClient.trace = options.trace
+	apiClient.apiKeyHeader = options.apiKeyHeader
+	apiClient.metrics = options.metrics
+	apiClient.tracer = options.tracer
+	apiClient.limiter = options.limiter
+	apiClient.maxResponseBytes = options.maxResponseBytes
+	apiClient.responseHook = options.responseHook
+	apiClient.autoFQDN = options.autoFQDN
+	if options.breakerFailures > 0 {
+		apiClient.breaker = newCircuitBreaker(options.breakerFailures, options.breakerCooldown)
+	}
+
+	apiClient.apiPath, err = parseAPIPath(options.apiPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiClient, nil
+}
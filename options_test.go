@@ -0,0 +1,681 @@
+package powerdns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+	"golang.org/x/time/rate"
+	. "gopkg.in/check.v1"
+)
+
+func (s *ClientSuite) TestNewClientWithOptions(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/api/v1/servers/other/zones")
+		c.Check(r.Header.Get("X-API-Key"), Equals, testAPIKey)
+		w.Write([]byte("[]")) // nolint: errcheck
+	}
+
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey,
+		WithServer("other"),
+		WithTimeout(time.Second),
+		WithKeepAlives(true))
+	c.Assert(err, IsNil)
+	c.Assert(pdnsCli.timeout, Equals, time.Second)
+	c.Assert(pdnsCli.cli.Transport.(*http.Transport).DisableKeepAlives, Equals, false)
+
+	// Several requests should be able to share a connection
+	for i := 0; i < 3; i++ {
+		_, err := pdnsCli.ListZones()
+		c.Assert(err, IsNil)
+	}
+}
+
+func (s *ClientSuite) TestNewClientWithHTTPClient(c *C) {
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]")) // nolint: errcheck
+	}))
+	defer tlsServer.Close()
+
+	pdnsCli, err := NewClientWithOptions(tlsServer.URL, testAPIKey, WithHTTPClient(tlsServer.Client()))
+	c.Assert(err, IsNil)
+	c.Assert(pdnsCli.cli, Equals, tlsServer.Client())
+
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+}
+
+// countingTransport counts the requests it sends with http.DefaultTransport.
+type countingTransport struct {
+	count int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.count++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (s *ClientSuite) TestWithTransport(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]")) // nolint: errcheck
+	}
+
+	transport := &countingTransport{}
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithTransport(transport))
+	c.Assert(err, IsNil)
+
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+	c.Assert(transport.count, Equals, 1)
+}
+
+func (s *ClientSuite) TestTimeoutWithKeepAlives(c *C) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") != "" {
+			select {
+			case <-unblock:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte("[]")) // nolint: errcheck
+	}
+
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey,
+		WithTimeout(time.Millisecond*100),
+		WithKeepAlives(true))
+	c.Assert(err, IsNil)
+
+	// The deadline applies to the whole request, even on a kept-alive connection
+	c.Assert(pdnsCli.DoRequest("zones", "GET", nil, nil), IsNil)
+	err = pdnsCli.DoRequest("zones?slow=1", "GET", nil, nil)
+	c.Assert(err, NotNil)
+	c.Assert(errwrap.Contains(err, ErrClientRequestFailed.Error()), Equals, true)
+
+	// But does not prevent later requests from succeeding
+	c.Assert(pdnsCli.DoRequest("zones", "GET", nil, nil), IsNil)
+}
+
+type loggedRequest struct {
+	method string
+	url    string
+	status int
+	err    error
+}
+
+type testLogger struct {
+	requests []loggedRequest
+}
+
+func (l *testLogger) LogRequest(method, url string, status int, dur time.Duration, err error) {
+	l.requests = append(l.requests, loggedRequest{method, url, status, err})
+}
+
+func (s *ClientSuite) TestWithLogger(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			writeJSON(c, w, http.StatusNotFound, shared.Error{Message: "Could not find domain"})
+			return
+		}
+		w.Write([]byte("[]")) // nolint: errcheck
+	}
+
+	logger := &testLogger{}
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithLogger(logger))
+	c.Assert(err, IsNil)
+
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+	err = pdnsCli.DeleteZone("missing.zone.")
+	c.Assert(IsNotFound(err), Equals, true)
+
+	c.Assert(len(logger.requests), Equals, 2)
+	c.Assert(logger.requests[0], DeepEquals,
+		loggedRequest{"GET", s.server.URL + "/api/v1/servers/localhost/zones", http.StatusOK, nil})
+	c.Assert(logger.requests[1].method, Equals, "DELETE")
+	c.Assert(logger.requests[1].status, Equals, http.StatusNotFound)
+	c.Assert(IsNotFound(logger.requests[1].err), Equals, true)
+
+	for _, req := range logger.requests {
+		c.Assert(strings.Contains(req.url, testAPIKey), Equals, false)
+	}
+
+	// Requests which get no response are logged with a zero status
+	s.server.Close()
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, NotNil)
+	c.Assert(len(logger.requests), Equals, 3)
+	c.Assert(logger.requests[2].status, Equals, 0)
+	c.Assert(logger.requests[2].err, NotNil)
+}
+
+func (s *ClientSuite) TestWithRequestTracing(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(c, w, http.StatusCreated, authoritative.ZoneResponse{
+			Zone: authoritative.Zone{Zone: shared.Zone{Name: "traced.zone."}},
+		})
+	}
+
+	// testAPIKey is also part of the default User-Agent, so use a key which can only appear if it is leaked.
+	apiKey := "trace-secret-key"
+	trace := &bytes.Buffer{}
+	pdnsCli, err := NewClientWithOptions(s.server.URL, apiKey, WithRequestTracing(trace))
+	c.Assert(err, IsNil)
+
+	req := authoritative.ZoneRequestNative{Zone: authoritative.Zone{Zone: shared.Zone{Name: "traced.zone."}}}
+	resp, err := pdnsCli.CreateZone(req)
+	c.Assert(err, IsNil)
+	// Tracing must not prevent the response being decoded
+	c.Assert(resp.Name, Equals, "traced.zone.")
+
+	output := trace.String()
+	c.Assert(strings.Contains(output, "> POST "+s.server.URL+"/api/v1/servers/localhost/zones\n"), Equals, true)
+	c.Assert(strings.Contains(output, "> X-API-Key: [REDACTED]\n"), Equals, true)
+	c.Assert(strings.Contains(output, apiKey), Equals, false)
+	c.Assert(strings.Contains(output, `"name":"traced.zone."`), Equals, true)
+	c.Assert(strings.Contains(output, "< 201 Created\n"), Equals, true)
+	// The response body follows the response headers
+	response := output[strings.Index(output, "< 201 Created"):]
+	c.Assert(strings.Contains(response, `"name":"traced.zone."`), Equals, true, Commentf("%s", output))
+}
+
+type observedRequest struct {
+	method        string
+	endpointClass string
+	status        int
+}
+
+type testObserver struct {
+	requests []observedRequest
+}
+
+func (o *testObserver) ObserveRequest(method, endpointClass string, status int, dur time.Duration, err error) {
+	o.requests = append(o.requests, observedRequest{method, endpointClass, status})
+}
+
+func (s *ClientSuite) TestWithMetrics(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/cryptokeys/1"):
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/statistics"):
+			w.Write([]byte("[]")) // nolint: errcheck
+		default:
+			writeJSON(c, w, http.StatusNotFound, shared.Error{Message: "Not Found"})
+		}
+	}
+
+	observer := &testObserver{}
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithMetrics(observer))
+	c.Assert(err, IsNil)
+
+	_, err = pdnsCli.GetZone("secret.zone.")
+	c.Assert(IsNotFound(err), Equals, true)
+	_, err = pdnsCli.Statistics()
+	c.Assert(err, IsNil)
+	c.Assert(pdnsCli.DoRequest("zones/secret.zone./cryptokeys/1", "PUT", nil, nil), IsNil)
+	_, err = pdnsCli.ListServers()
+	c.Assert(IsNotFound(err), Equals, true)
+
+	c.Assert(observer.requests, DeepEquals, []observedRequest{
+		{"GET", "zones", http.StatusNotFound},
+		{"GET", "statistics", http.StatusOK},
+		{"PUT", "cryptokeys", http.StatusNoContent},
+		{"GET", "servers", http.StatusNotFound},
+	})
+}
+
+func (s *ClientSuite) TestPrometheusMetrics(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/statistics") {
+			w.Write([]byte("[]")) // nolint: errcheck
+			return
+		}
+		writeJSON(c, w, http.StatusNotFound, shared.Error{Message: "Not Found"})
+	}
+
+	metrics := NewPrometheusMetrics("pdns_client")
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithMetrics(metrics))
+	c.Assert(err, IsNil)
+
+	_, err = pdnsCli.Statistics()
+	c.Assert(err, IsNil)
+	_, err = pdnsCli.Statistics()
+	c.Assert(err, IsNil)
+	_, err = pdnsCli.GetZone("secret.zone.")
+	c.Assert(IsNotFound(err), Equals, true)
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	c.Check(rec.Header().Get("Content-Type"), Equals, "text/plain; version=0.0.4; charset=utf-8")
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE pdns_client_requests_total counter",
+		`pdns_client_requests_total{method="GET",endpoint="statistics",code="200"} 2`,
+		`pdns_client_requests_total{method="GET",endpoint="zones",code="404"} 1`,
+		"# TYPE pdns_client_request_duration_seconds histogram",
+		`pdns_client_request_duration_seconds_bucket{method="GET",endpoint="statistics",le="+Inf"} 2`,
+		`pdns_client_request_duration_seconds_count{method="GET",endpoint="statistics"} 2`,
+		`pdns_client_request_duration_seconds_count{method="GET",endpoint="zones"} 1`,
+	} {
+		c.Check(strings.Contains(body, line+"\n"), Equals, true, Commentf("missing %q in:\n%s", line, body))
+	}
+	c.Check(strings.Contains(body, "secret.zone."), Equals, false)
+}
+
+type testSpanKey struct{}
+
+type testSpan struct {
+	name       string
+	parent     interface{}
+	attributes map[string]interface{}
+	ended      bool
+	err        error
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *testSpan) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	span := &testSpan{name: name, parent: ctx.Value(testSpanKey{}), attributes: map[string]interface{}{}}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+func (s *ClientSuite) TestWithTracer(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(c, w, http.StatusNotFound, shared.Error{Message: "Not Found"})
+	}
+
+	tracer := &testTracer{}
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithTracer(tracer))
+	c.Assert(err, IsNil)
+
+	ctx := context.WithValue(context.Background(), testSpanKey{}, "reconcile")
+	err = pdnsCli.DoRequestContext(ctx, "zones/example.com./metadata", "GET", nil, nil)
+	c.Assert(IsNotFound(err), Equals, true)
+
+	c.Assert(len(tracer.spans), Equals, 1)
+	span := tracer.spans[0]
+	c.Assert(span.name, Equals, "PowerDNS GET metadata")
+	c.Assert(span.parent, Equals, "reconcile")
+	c.Assert(span.attributes, DeepEquals, map[string]interface{}{
+		"http.method":      "GET",
+		"http.url":         s.server.URL + "/api/v1/servers/localhost/zones/example.com./metadata",
+		"http.status_code": http.StatusNotFound,
+		"powerdns.zone":    "example.com.",
+	})
+	c.Assert(span.ended, Equals, true)
+	c.Assert(span.err, Equals, err)
+}
+
+func (s *ClientSuite) TestWithRateLimiter(c *C) {
+	requests := 0
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("[]")) // nolint: errcheck
+	}
+
+	// A burst of one, refilled far slower than the test runs, allows exactly one request.
+	limiter := rate.NewLimiter(rate.Every(time.Hour), 1)
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithRateLimiter(limiter))
+	c.Assert(err, IsNil)
+
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = pdnsCli.DoRequestContext(ctx, "zones", "GET", nil, nil)
+	c.Assert(errwrap.Contains(err, ErrClientRateLimitWait.Error()), Equals, true)
+	c.Assert(requests, Equals, 1)
+}
+
+func (s *ClientSuite) TestWithAPIPath(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/dns/api/v2/servers/localhost/zones")
+		w.Write([]byte("[]")) // nolint: errcheck
+	}
+
+	// The trailing slash is optional
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithAPIPath("dns/api/v2"))
+	c.Assert(err, IsNil)
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+
+	_, err = NewClientWithOptions(s.server.URL, testAPIKey, WithAPIPath("http://other/api/v1/"))
+	c.Assert(err, Equals, ErrClientRequestIsAbs)
+}
+
+func (s *ClientSuite) TestClientsWithDifferentAPIPaths(c *C) {
+	var mtx sync.Mutex
+	paths := map[string]int{}
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		paths[r.URL.Path]++
+		mtx.Unlock()
+		w.Write([]byte("[]")) // nolint: errcheck
+	}
+
+	proxiedCli, err := NewClientWithOptions(s.server.URL+"/dns/", testAPIKey)
+	c.Assert(err, IsNil)
+	directCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithAPIPath("/api/v1/"))
+	c.Assert(err, IsNil)
+
+	wg := sync.WaitGroup{}
+	for _, cli := range []*Client{proxiedCli, directCli, proxiedCli, directCli} {
+		wg.Add(1)
+		go func(cli *Client) {
+			defer wg.Done()
+			_, err := cli.ListZones()
+			c.Check(err, IsNil)
+		}(cli)
+	}
+	wg.Wait()
+
+	c.Assert(paths, DeepEquals, map[string]int{
+		"/dns/api/v1/servers/localhost/zones": 2,
+		"/api/v1/servers/localhost/zones":     2,
+	})
+}
+
+func (s *ClientSuite) TestEndpointPathPrefix(c *C) {
+	var requestPath string
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		requestPath = r.URL.Path
+		w.Write([]byte("[]")) // nolint: errcheck
+	}
+
+	for endpoint, expected := range map[string]string{
+		s.server.URL:              "/api/v1/servers/localhost/zones",
+		s.server.URL + "/":        "/api/v1/servers/localhost/zones",
+		s.server.URL + "/prefix":  "/prefix/api/v1/servers/localhost/zones",
+		s.server.URL + "/prefix/": "/prefix/api/v1/servers/localhost/zones",
+	} {
+		pdnsCli, err := NewClient(endpoint, testAPIKey, false, time.Second)
+		c.Assert(err, IsNil)
+		_, err = pdnsCli.ListZones()
+		c.Assert(err, IsNil)
+		c.Check(requestPath, Equals, expected, Commentf("endpoint %s", endpoint))
+	}
+}
+
+func (s *ClientSuite) TestInvalidEndpoint(c *C) {
+	for _, endpoint := range []string{"localhost:8081", "ftp://localhost/", "http:///api", "/api/v1"} {
+		_, err := NewClient(endpoint, testAPIKey, false, time.Second)
+		c.Check(err, Equals, ErrClientInvalidEndpoint, Commentf("endpoint %s", endpoint))
+	}
+
+	for _, endpoint := range []string{"http://localhost:8081", "HTTPS://localhost/"} {
+		_, err := NewClient(endpoint, testAPIKey, false, time.Second)
+		c.Check(err, IsNil, Commentf("endpoint %s", endpoint))
+	}
+}
+
+func (s *ClientSuite) TestWithBearerToken(c *C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), Equals, "Bearer gateway-token")
+		_, hasAPIKey := r.Header["X-Api-Key"]
+		c.Check(hasAPIKey, Equals, false)
+		w.Write([]byte("[]")) // nolint: errcheck
+	}
+
+	pdnsCli, err := NewClientWithOptions(s.server.URL, "", WithBearerToken("gateway-token"))
+	c.Assert(err, IsNil)
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+}
+
+func (s *ClientSuite) TestWithAPIKeyHeader(c *C) {
+	apiKey := "gateway-secret-key"
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("X-Gateway-Key"), Equals, apiKey)
+		w.Write([]byte("[]")) // nolint: errcheck
+	}
+
+	trace := &bytes.Buffer{}
+	pdnsCli, err := NewClientWithOptions(s.server.URL, apiKey,
+		WithAPIKeyHeader("X-Gateway-Key"), WithRequestTracing(trace))
+	c.Assert(err, IsNil)
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+
+	// The custom header must be redacted from traces too
+	c.Assert(strings.Contains(trace.String(), "> X-Gateway-Key: [REDACTED]\n"), Equals, true)
+	c.Assert(strings.Contains(trace.String(), apiKey), Equals, false)
+}
+
+func (s *ClientSuite) TestWithUserAgent(c *C) {
+	userAgent := ""
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.Write([]byte("[]")) // nolint: errcheck
+	}
+
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey)
+	c.Assert(err, IsNil)
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+	c.Assert(userAgent, Equals, defaultUserAgent())
+	c.Assert(strings.HasPrefix(userAgent, "go.powerdns/"), Equals, true)
+	c.Assert(len(userAgent) > len("go.powerdns/"), Equals, true)
+
+	// The version can be set at link time
+	defer func(saved string) { version = saved }(version)
+	version = "v1.2.3"
+	pdnsCli, err = NewClientWithOptions(s.server.URL, testAPIKey)
+	c.Assert(err, IsNil)
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+	c.Assert(userAgent, Equals, "go.powerdns/v1.2.3")
+
+	pdnsCli, err = NewClientWithOptions(s.server.URL, testAPIKey, WithUserAgent("my-dns-controller/1.2"))
+	c.Assert(err, IsNil)
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+	c.Assert(userAgent, Equals, "my-dns-controller/1.2")
+}
+
+func (s *ClientSuite) TestWithMaxResponseBytes(c *C) {
+	body := `[{"name": "limited.zone."}]`
+	status := http.StatusOK
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body)) // nolint: errcheck
+	}
+
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithMaxResponseBytes(int64(len(body))))
+	c.Assert(err, IsNil)
+
+	// A response of exactly the limit is allowed.
+	zones, err := pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+	c.Assert(zones, HasLen, 1)
+
+	body = `[{"name": "limited.zone."}, {"name": "too-many.zone."}]`
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, Equals, ErrClientResponseTooLarge)
+
+	next, err := pdnsCli.IterZones(context.Background())
+	c.Assert(err, IsNil)
+	for err == nil {
+		_, err = next()
+	}
+	c.Assert(errwrap.Contains(err, ErrClientResponseTooLarge.Error()), Equals, true)
+
+	// Error responses are limited too.
+	status = http.StatusInternalServerError
+	body = strings.Repeat("x", 1024)
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, Equals, ErrClientResponseTooLarge)
+}
+
+func (s *ClientSuite) TestWithResponseHook(c *C) {
+	status := http.StatusOK
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "41")
+		writeJSON(c, w, status, []authoritative.ZoneResponse{})
+	}
+
+	calls := 0
+	hook := func(req *http.Request, resp *http.Response) {
+		calls++
+		c.Check(req.URL.Path, Equals, "/api/v1/servers/localhost/zones")
+		c.Check(resp.StatusCode, Equals, status)
+		c.Check(resp.Header.Get("X-RateLimit-Remaining"), Equals, "41")
+	}
+
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithResponseHook(hook))
+	c.Assert(err, IsNil)
+
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 1)
+
+	// Error responses are seen too.
+	status = http.StatusUnprocessableEntity
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, NotNil)
+	c.Assert(calls, Equals, 2)
+}
+
+func (s *ClientSuite) TestWithCircuitBreaker(c *C) {
+	status := http.StatusServiceUnavailable
+	requests := 0
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeJSON(c, w, status, []authoritative.ZoneResponse{})
+	}
+
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithCircuitBreaker(2, time.Minute))
+	c.Assert(err, IsNil)
+	now := time.Now()
+	pdnsCli.breaker.now = func() time.Time { return now }
+
+	// Client errors do not count as failures.
+	status = http.StatusUnprocessableEntity
+	for i := 0; i < 3; i++ {
+		_, err = pdnsCli.ListZones()
+		c.Assert(err, Not(Equals), ErrCircuitOpen)
+	}
+
+	// The breaker opens after two consecutive failures.
+	status = http.StatusServiceUnavailable
+	for i := 0; i < 2; i++ {
+		_, err = pdnsCli.ListZones()
+		c.Assert(err, Not(Equals), ErrCircuitOpen)
+	}
+	c.Assert(requests, Equals, 5)
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, Equals, ErrCircuitOpen)
+	c.Assert(requests, Equals, 5)
+
+	// After the cooldown a failed probe reopens it.
+	now = now.Add(time.Minute)
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, Not(Equals), ErrCircuitOpen)
+	c.Assert(requests, Equals, 6)
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, Equals, ErrCircuitOpen)
+
+	// A successful probe closes it.
+	now = now.Add(time.Minute)
+	status = http.StatusOK
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+	_, err = pdnsCli.ListZones()
+	c.Assert(err, IsNil)
+	c.Assert(requests, Equals, 8)
+}
+
+func (s *ClientSuite) TestWithAutoFQDN(c *C) {
+	var patch authoritative.PatchZoneRequest
+	var create authoritative.ZoneRequestNative
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/api/v1/servers/localhost/zones":
+			c.Assert(json.NewDecoder(r.Body).Decode(&create), IsNil)
+			writeJSON(c, w, http.StatusCreated, authoritative.ZoneResponse{Zone: create.Zone})
+		case r.Method == "PATCH" && r.URL.Path == "/api/v1/servers/localhost/zones/fqdn.zone.":
+			c.Assert(json.NewDecoder(r.Body).Decode(&patch), IsNil)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			c.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}
+
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithAutoFQDN())
+	c.Assert(err, IsNil)
+
+	rrsets := shared.RRsets{
+		{Name: "www.fqdn.zone", Type: "CNAME", TTL: 60, Records: shared.Records{{Content: "web.fqdn.zone"}}},
+		{Name: "fqdn.zone", Type: "MX", TTL: 60, Records: shared.Records{{Content: "10 mail.fqdn.zone"}}},
+		{Name: "_sip._udp.fqdn.zone", Type: "SRV", TTL: 60,
+			Records: shared.Records{{Content: "10 5 5060 sip.fqdn.zone."}}},
+		{Name: "txt.fqdn.zone", Type: "TXT", TTL: 60, Records: shared.Records{{Content: `"not.a.name"`}}},
+	}
+	_, err = pdnsCli.CreateZone(authoritative.ZoneRequestNative{
+		Zone:        authoritative.Zone{Zone: shared.Zone{Name: "fqdn.zone", RRsets: rrsets}},
+		Nameservers: []string{"ns1.fqdn.zone"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(create.Name, Equals, "fqdn.zone.")
+	c.Assert(create.Nameservers, DeepEquals, []string{"ns1.fqdn.zone."})
+	c.Assert(create.RRsets[0].Name, Equals, "www.fqdn.zone.")
+	c.Assert(create.RRsets[0].Records[0].Content, Equals, "web.fqdn.zone.")
+	c.Assert(create.RRsets[1].Records[0].Content, Equals, "10 mail.fqdn.zone.")
+	c.Assert(create.RRsets[2].Records[0].Content, Equals, "10 5 5060 sip.fqdn.zone.")
+	c.Assert(create.RRsets[3].Records[0].Content, Equals, `"not.a.name"`)
+	// The caller's RRsets are not modified.
+	c.Assert(rrsets[0].Name, Equals, "www.fqdn.zone")
+
+	err = pdnsCli.PatchZone("fqdn.zone", authoritative.NewPatchRRSets(rrsets[:1], authoritative.RRsetReplace))
+	c.Assert(err, IsNil)
+	c.Assert(patch.RRSets, HasLen, 1)
+	c.Assert(patch.RRSets[0].Name, Equals, "www.fqdn.zone.")
+	c.Assert(patch.RRSets[0].Records[0].Content, Equals, "web.fqdn.zone.")
+	c.Assert(patch.RRSets[0].ChangeType, Equals, authoritative.RRsetReplace)
+}
+
+func (s *ClientSuite) TestWithAutoFQDNKeepsNilRecords(c *C) {
+	var body []byte
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	pdnsCli, err := NewClientWithOptions(s.server.URL, testAPIKey, WithAutoFQDN())
+	c.Assert(err, IsNil)
+
+	// Empty records would make the server delete the RRset rather than just replace its comments.
+	err = pdnsCli.SetRRsetComments("fqdn.zone", "www.fqdn.zone", "A",
+		[]shared.Comment{{Content: "web server", Account: "ops"}})
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Matches, `.*"name":"www\.fqdn\.zone\.".*`)
+	c.Assert(string(body), Matches, `.*"records":null.*`)
+}
@@ -1,12 +1,15 @@
 package authoritative_test
 
 import (
+	"encoding/json"
+	"fmt"
 	"testing"
 
 	. "gopkg.in/check.v1"
 
 	"github.com/drhodes/golorem"
 	. "github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
 	"github.com/wrouesnel/go.powerdns/testutil"
 )
 
@@ -53,4 +56,181 @@ func (a *AuthTypeSuite) TestPatchRRSets(c *C) {
 	prrs := NewPatchRRSets(rrs, RRsetReplace)
 	rtrrs := prrs.CopyToRRSets()
 	c.Assert(rrs.Equals(rtrrs), Equals, true)
+
+	// Neither conversion should pad the result with zero-value elements
+	c.Assert(len(prrs), Equals, len(rrs))
+	c.Assert(len(rtrrs), Equals, len(rrs))
+	c.Assert(rtrrs, DeepEquals, rrs)
+}
+
+func (a *AuthTypeSuite) TestReconcileRRsets(c *C) {
+	actual := shared.RRsets{
+		{Name: "same.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.1"}}},
+		{Name: "records.test.zone.", Type: "A", TTL: 300, Records: shared.Records{
+			{Content: "192.0.2.2"}, {Content: "192.0.2.3"},
+		}},
+		{Name: "ttl.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.4"}}},
+		{Name: "removed.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.5"}}},
+		// Same name as a desired RRset, but a different type
+		{Name: "same.test.zone.", Type: "TXT", TTL: 300, Records: shared.Records{{Content: "\"removed\""}}},
+	}
+
+	desired := shared.RRsets{
+		{Name: "same.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.1"}}},
+		// A record removed from an existing RRset
+		{Name: "records.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.2"}}},
+		{Name: "ttl.test.zone.", Type: "A", TTL: 60, Records: shared.Records{{Content: "192.0.2.4"}}},
+		{Name: "added.test.zone.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.6"}}},
+	}
+
+	patch := ReconcileRRsets(desired, actual)
+	c.Assert(len(patch), Equals, 5)
+
+	expected := []struct {
+		name       shared.RRsetUniqueName
+		changeType RRsetChangeType
+	}{
+		{shared.RRsetUniqueName{Name: "records.test.zone.", Type: "A"}, RRsetReplace},
+		{shared.RRsetUniqueName{Name: "ttl.test.zone.", Type: "A"}, RRsetReplace},
+		{shared.RRsetUniqueName{Name: "added.test.zone.", Type: "A"}, RRsetReplace},
+		{shared.RRsetUniqueName{Name: "removed.test.zone.", Type: "A"}, RRSetDelete},
+		{shared.RRsetUniqueName{Name: "same.test.zone.", Type: "TXT"}, RRSetDelete},
+	}
+	for idx, e := range expected {
+		c.Check(patch[idx].UniqueName(), Equals, e.name)
+		c.Check(patch[idx].ChangeType, Equals, e.changeType)
+	}
+
+	// Replaced RRsets carry the complete desired RRset
+	c.Assert(patch[0].Records, DeepEquals, desired[1].Records)
+	c.Assert(patch[1].TTL, Equals, uint32(60))
+
+	// Reconciling identical RRsets does nothing
+	c.Assert(len(ReconcileRRsets(desired, desired)), Equals, 0)
+	c.Assert(len(ReconcileRRsets(shared.RRsets{}, shared.RRsets{})), Equals, 0)
+}
+
+func (a *AuthTypeSuite) TestKindIsValid(c *C) {
+	for _, kind := range []Kind{KindNative, KindMaster, KindSlave} {
+		c.Check(kind.IsValid(), Equals, true)
+	}
+	for _, kind := range []Kind{"", "native", "Primary"} {
+		c.Check(kind.IsValid(), Equals, false)
+	}
+}
+
+func (a *AuthTypeSuite) TestZoneUpdateOmitsRRsets(c *C) {
+	kind := KindMaster
+	update, err := json.Marshal(ZoneUpdate{Kind: &kind})
+	c.Assert(err, IsNil)
+	c.Assert(string(update), Equals, `{"kind":"Master"}`)
+
+	// Whereas a Zone always carries its RRsets, even if empty
+	zone, err := json.Marshal(Zone{Kind: KindMaster})
+	c.Assert(err, IsNil)
+	fields := map[string]interface{}{}
+	c.Assert(json.Unmarshal(zone, &fields), IsNil)
+	_, hasRRsets := fields["rrsets"]
+	c.Assert(hasRRsets, Equals, true)
+}
+
+func (a *AuthTypeSuite) TestZoneUpdateOmitsUnsetFields(c *C) {
+	update, err := json.Marshal(ZoneUpdate{})
+	c.Assert(err, IsNil)
+	c.Assert(string(update), Equals, `{}`)
+
+	// Zero values are sent when set, so settings can be cleared
+	account := ""
+	dnssec := false
+	update, err = json.Marshal(ZoneUpdate{Account: &account, DNSsec: &dnssec})
+	c.Assert(err, IsNil)
+	c.Assert(string(update), Equals, `{"dnssec":false,"account":""}`)
+
+	soaEdit := SoaEditValueNone
+	update, err = json.Marshal(ZoneUpdate{SoaEditAPI: &soaEdit})
+	c.Assert(err, IsNil)
+	c.Assert(string(update), Equals, `{"soa_edit_api":"NONE"}`)
+}
+
+func (a *AuthTypeSuite) TestZoneNSEC3Fields(c *C) {
+	// Unset NSEC3 fields are omitted so zones are created with the server defaults
+	zone, err := json.Marshal(Zone{Kind: KindNative})
+	c.Assert(err, IsNil)
+	fields := map[string]interface{}{}
+	c.Assert(json.Unmarshal(zone, &fields), IsNil)
+	for _, field := range []string{"nsec3param", "nsec3narrow", "presigned"} {
+		_, found := fields[field]
+		c.Check(found, Equals, false, Commentf("%s", field))
+	}
+
+	decoded := Zone{}
+	c.Assert(json.Unmarshal([]byte(`{"nsec3param": "1 0 1 ab", "nsec3narrow": true, "presigned": true}`),
+		&decoded), IsNil)
+	c.Assert(decoded.NSEC3Param, Equals, "1 0 1 ab")
+	c.Assert(decoded.NSEC3Narrow, Equals, true)
+	c.Assert(decoded.Presigned, Equals, true)
+
+	changed := decoded.Copy()
+	changed.NSEC3Param = ""
+	c.Assert(decoded.HeaderEquals(changed), Equals, false)
+}
+
+func (a *AuthTypeSuite) TestPatchAfterDedupHasNoDuplicates(c *C) {
+	// RRsets merged from several sources, each of which lists some of the same records
+	sources := []shared.RRsets{testutil.MakeRRsets("test.zone."), testutil.MakeRRsets("test.zone.")}
+	merged := sources[0].Copy()
+	for idx := range merged {
+		merged[idx].Records = append(merged[idx].Records, merged[idx].Records...)
+		merged[idx].Records = append(merged[idx].Records, sources[1][0].Records...)
+		merged[idx].Records = append(merged[idx].Records, sources[1][0].Records...)
+	}
+
+	merged.Dedup()
+	for _, rrset := range NewPatchRRSets(merged, RRsetReplace) {
+		contents := map[string]bool{}
+		for _, record := range rrset.Records {
+			key := fmt.Sprintf("%s/%v", record.Content, record.Disabled)
+			c.Assert(contents[key], Equals, false, Commentf("duplicate record %s in %s", key, rrset.Name))
+			contents[key] = true
+		}
+		c.Assert(rrset.Records.Equals(sources[0].ToMap()[rrset.UniqueName()].Records.Union(sources[1][0].Records)),
+			Equals, true)
+	}
+}
+
+func (a *AuthTypeSuite) TestZoneTemplate(c *C) {
+	template := ZoneTemplate{
+		SoaEdit:     SoaEditValueInceptionIncrement,
+		SoaEditAPI:  SoaEditValueIncrementWeeks,
+		DefaultTTL:  3600,
+		Nameservers: []string{"ns1.example.net.", "ns2.example.net."},
+		Account:     "provisioning",
+	}
+
+	rrsets := shared.RRsets{
+		{Name: "www.templated.zone.", Type: "A", Records: shared.Records{{Content: "192.0.2.1"}}},
+		{Name: "mail.templated.zone.", Type: "A", TTL: 60, Records: shared.Records{{Content: "192.0.2.2"}}},
+	}
+	req := template.NewNativeZone("Templated.Zone", rrsets)
+	c.Assert(req.Name, Equals, "templated.zone.")
+	c.Assert(req.Kind, Equals, KindNative)
+	c.Assert(req.SoaEdit, Equals, SoaEditValueInceptionIncrement)
+	c.Assert(req.SoaEditAPI, Equals, SoaEditValueIncrementWeeks)
+	c.Assert(req.Account, Equals, "provisioning")
+	c.Assert(req.Nameservers, DeepEquals, template.Nameservers)
+	c.Assert(req.RRsets[0].TTL, Equals, uint32(3600))
+	c.Assert(req.RRsets[1].TTL, Equals, uint32(60))
+	// The given RRsets are not modified.
+	c.Assert(rrsets[0].TTL, Equals, uint32(0))
+
+	// An apex NS RRset replaces the template's nameservers, since the server rejects both.
+	rrsets = append(rrsets, shared.RRset{Name: "templated.zone.", Type: "NS",
+		Records: shared.Records{{Content: "ns.templated.zone."}}})
+	req = template.NewNativeZone("templated.zone.", rrsets)
+	c.Assert(req.Nameservers, DeepEquals, []string{})
+
+	// The apex NS RRset is recognised without its trailing dot too.
+	rrsets[2].Name = "Templated.Zone"
+	req = template.NewNativeZone("templated.zone.", rrsets)
+	c.Assert(req.Nameservers, DeepEquals, []string{})
 }
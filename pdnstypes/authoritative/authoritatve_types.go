@@ -1,6 +1,8 @@
 package authoritative
 
 import (
+	"strings"
+
 	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
 )
 
@@ -14,6 +16,16 @@ const (
 	KindSlave  Kind = "Slave"
 )
 
+// IsValid returns true if the Kind is one of the Kind constants.
+func (k Kind) IsValid() bool {
+	switch k {
+	case KindNative, KindMaster, KindSlave:
+		return true
+	default:
+		return false
+	}
+}
+
 // SoaEditValue should only ever be one of an SoaEditValue constant. The available constants are
 // only those from the recommended set.
 type SoaEditValue string
@@ -40,13 +52,13 @@ type Zone struct {
 	shared.Zone
 	Kind   Kind `json:"kind"`
 	DNSsec bool `json:"dnssec"`
-	// The following are unimplemented as per the API spec
-	//"nsec3param": "<nsec3param record>",
-	//"nsec3narrow": <bool>,
-	//"presigned": <bool>,
-	SoaEdit    SoaEditValue `json:"soa_edit"`
-	SoaEditAPI SoaEditValue `json:"soa_edit_api"`
-	Account    string       `json:"account,omitempty"`
+	// NSEC3Param is the NSEC3PARAM record content of the zone, e.g. "1 0 1 ab", or empty if it uses NSEC.
+	NSEC3Param  string       `json:"nsec3param,omitempty"`
+	NSEC3Narrow bool         `json:"nsec3narrow,omitempty"`
+	Presigned   bool         `json:"presigned,omitempty"`
+	SoaEdit     SoaEditValue `json:"soa_edit"`
+	SoaEditAPI  SoaEditValue `json:"soa_edit_api"`
+	Account     string       `json:"account,omitempty"`
 }
 
 // HeaderEquals compares the Zone header metadata that would match between a ZoneRequest and a ZoneResponse.
@@ -55,6 +67,9 @@ func (z *Zone) HeaderEquals(a Zone) bool {
 	return z.Zone.HeaderEquals(a.Zone) &&
 		z.Kind == a.Kind &&
 		z.DNSsec == a.DNSsec &&
+		z.NSEC3Param == a.NSEC3Param &&
+		z.NSEC3Narrow == a.NSEC3Narrow &&
+		z.Presigned == a.Presigned &&
 		z.SoaEdit == a.SoaEdit &&
 		z.SoaEditAPI == a.SoaEditAPI &&
 		z.Account == a.Account
@@ -73,31 +88,108 @@ func (z *Zone) Copy() Zone {
 	return r
 }
 
+// ZoneUpdate implements the zone header fields which can be changed with a PUT to an existing zone. Unlike Zone it
+// has no RRsets, so it can never replace the contents of the zone; sending a Zone, which always includes its
+// RRsets, is the only way to do that. Nil fields are omitted, and so left unchanged by the server.
+type ZoneUpdate struct {
+	Kind       *Kind         `json:"kind,omitempty"`
+	DNSsec     *bool         `json:"dnssec,omitempty"`
+	SoaEdit    *SoaEditValue `json:"soa_edit,omitempty"`
+	SoaEditAPI *SoaEditValue `json:"soa_edit_api,omitempty"`
+	Account    *string       `json:"account,omitempty"`
+	// NSEC3Param set to an empty string switches the zone back to NSEC.
+	NSEC3Param  *string `json:"nsec3param,omitempty"`
+	NSEC3Narrow *bool   `json:"nsec3narrow,omitempty"`
+	Presigned   *bool   `json:"presigned,omitempty"`
+	// Masters replaces the masters of a slave zone.
+	Masters *[]string `json:"masters,omitempty"`
+}
+
 // ZoneResponse implements the extra fields which are included in a response from a PowerDNS server. It should not
 // be used to send a Zone request.
 type ZoneResponse struct {
 	Zone
 	Serial         uint32 `json:"serial"`
 	NotifiedSerial uint32 `json:"notified_serial"`
+	// Masters are the servers a slave zone is transferred from. They are empty for other kinds of zone.
+	Masters []string `json:"masters"`
 }
 
 // ZoneRequestMaster implements the fields used when creating a master zone
 type ZoneRequestMaster struct {
 	Zone
+	// Nameservers is as for ZoneRequestNative.
 	Nameservers []string `json:"nameservers"`
 }
 
 // ZoneRequestSlave implements the fields used when creating a slave zone
 type ZoneRequestSlave struct {
 	Zone
+	// Masters are the addresses, optionally with a port, of the servers the zone is transferred from, e.g.
+	// "192.0.2.1" or "[2001:db8::1]:5300". The zone never transfers without them.
+	Masters []string `json:"masters"`
 }
 
 // ZoneRequestNative implements the fields used when creating a native zone
 type ZoneRequestNative struct {
 	Zone
+	// Nameservers is a shortcut for an NS RRset at the zone apex. The server rejects requests which set both, so
+	// leave it empty when RRsets contains the NS RRset.
 	Nameservers []string `json:"nameservers"`
 }
 
+// ZoneTemplate holds the defaults shared by many zones, so they can be created with a consistent policy.
+type ZoneTemplate struct {
+	SoaEdit    SoaEditValue
+	SoaEditAPI SoaEditValue
+	// DefaultTTL is given to RRsets which have no TTL set.
+	DefaultTTL uint32
+	// Nameservers are used unless the RRsets of a zone include an NS RRset at its apex.
+	Nameservers []string
+	Account     string
+}
+
+// canonicalName adds the trailing dot to name if it is missing, as the client does when it checks for an apex NS
+// RRset.
+func canonicalName(name string) string {
+	if !strings.HasSuffix(name, ".") {
+		return name + "."
+	}
+	return name
+}
+
+// NewNativeZone returns a request to create the named native zone with the template's defaults, containing copies of
+// rrsets. The zone name is normalized.
+func (t ZoneTemplate) NewNativeZone(name string, rrsets shared.RRsets) ZoneRequestNative {
+	req := ZoneRequestNative{
+		Zone: Zone{
+			Zone:       shared.Zone{Name: name, RRsets: rrsets.Copy()},
+			Kind:       KindNative,
+			SoaEdit:    t.SoaEdit,
+			SoaEditAPI: t.SoaEditAPI,
+			Account:    t.Account,
+		},
+		Nameservers: []string{},
+	}
+	req.Zone.Normalize()
+
+	hasApexNS := false
+	for idx := range req.RRsets {
+		rrset := &req.RRsets[idx]
+		if rrset.TTL == 0 {
+			rrset.TTL = t.DefaultTTL
+		}
+		if strings.EqualFold(rrset.Type, "NS") && strings.EqualFold(canonicalName(rrset.Name), req.Name) {
+			hasApexNS = true
+		}
+	}
+
+	if !hasApexNS {
+		req.Nameservers = append(req.Nameservers, t.Nameservers...)
+	}
+	return req
+}
+
 // PatchRRsets is a collection of PatchRRSet structs suitable for use with a patch request.
 type PatchRRSets []PatchRRSet
 
@@ -114,6 +206,46 @@ func NewPatchRRSets(rrsets shared.RRsets, changetype RRsetChangeType) PatchRRSet
 	return result
 }
 
+// ReconcileRRsets returns the minimal set of changes which converts the actual RRsets of a zone into the desired
+// ones: a REPLACE for each desired RRset which is missing or differs, and a DELETE for each actual RRset whose name
+// and type are not desired at all. Since RRsets not in desired are deleted, desired should include the zone's SOA
+// and NS RRsets. The changes are ordered as desired then actual.
+func ReconcileRRsets(desired, actual shared.RRsets) PatchRRSets {
+	desiredMap := desired.ToMap()
+	actualMap := actual.ToMap()
+	result := PatchRRSets{}
+
+	seen := make(map[shared.RRsetUniqueName]bool, len(desiredMap))
+	for _, rrset := range desired {
+		name := rrset.UniqueName()
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		// Use the map entry so duplicates resolve the same way as ToMap.
+		want := desiredMap[name]
+		if current, found := actualMap[name]; !found || !current.Equals(want) {
+			result = append(result, PatchRRSet{RRset: want, ChangeType: RRsetReplace})
+		}
+	}
+
+	for _, rrset := range actual {
+		name := rrset.UniqueName()
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		result = append(result, PatchRRSet{
+			RRset:      shared.RRset{Name: rrset.Name, Type: rrset.Type},
+			ChangeType: RRSetDelete,
+		})
+	}
+
+	return result
+}
+
 // CopyToRRSets makes a value-based copy of all contained RRsets and returns a regular
 // RRset object.
 func (prrs PatchRRSets) CopyToRRSets() shared.RRsets {
@@ -142,3 +274,65 @@ type PatchZoneRequest struct {
 
 // PatchZoneResponse implements the fields used when receiving the result of a successful zone PATCH request
 type PatchZoneResponse ZoneResponse
+
+// Cryptokey implements a DNSSEC key belonging to a zone. Only Active is sent when toggling a key, so the remaining
+// fields are omitted when empty.
+type Cryptokey struct {
+	Type       string   `json:"type,omitempty"`
+	ID         int      `json:"id,omitempty"`
+	KeyType    string   `json:"keytype,omitempty"`
+	Active     bool     `json:"active"`
+	DNSKey     string   `json:"dnskey,omitempty"`
+	DS         []string `json:"ds,omitempty"`
+	PrivateKey string   `json:"privatekey,omitempty"`
+	Algorithm  string   `json:"algorithm,omitempty"`
+	Bits       int      `json:"bits,omitempty"`
+}
+
+// TSIGKey implements a TSIG key used to authenticate zone transfers. When creating a key, leaving Key empty asks
+// the server to generate the key material.
+type TSIGKey struct {
+	Name      string `json:"name"`
+	ID        string `json:"id,omitempty"`
+	Algorithm string `json:"algorithm"`
+	Key       string `json:"key,omitempty"`
+	Type      string `json:"type,omitempty"`
+}
+
+// Metadata implements one kind of domain metadata of a zone, e.g. ALSO-NOTIFY, with all of its values.
+type Metadata struct {
+	Kind     string   `json:"kind"`
+	Metadata []string `json:"metadata"`
+}
+
+// Autoprimary implements an autoprimary (supermaster): a primary server from which zones are automatically created
+// when it sends a NOTIFY, provided it is listed as a nameserver of the zone.
+type Autoprimary struct {
+	IP         string `json:"ip"`
+	Nameserver string `json:"nameserver"`
+	Account    string `json:"account,omitempty"`
+}
+
+// SearchObjectType restricts the kind of objects returned by a search.
+type SearchObjectType string
+
+// nolint: golint
+const (
+	SearchObjectTypeAll     SearchObjectType = "all"
+	SearchObjectTypeZone    SearchObjectType = "zone"
+	SearchObjectTypeRecord  SearchObjectType = "record"
+	SearchObjectTypeComment SearchObjectType = "comment"
+)
+
+// SearchResult implements a single result from a search of zones, records and comments. Which fields are
+// populated depends on ObjectType.
+type SearchResult struct {
+	Content    string           `json:"content,omitempty"`
+	Disabled   bool             `json:"disabled,omitempty"`
+	Name       string           `json:"name"`
+	ObjectType SearchObjectType `json:"object_type"`
+	Zone       string           `json:"zone,omitempty"`
+	ZoneID     string           `json:"zone_id"`
+	Type       string           `json:"type,omitempty"`
+	TTL        uint32           `json:"ttl,omitempty"`
+}
@@ -0,0 +1,155 @@
+// Package records implements typed parsing and formatting of record content strings whose fields are packed
+// together, such as MX and SRV records.
+package records
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wrouesnel/go.powerdns/internal/errs"
+)
+
+// nolint: golint
+var (
+	ErrMXInvalid  = errors.New("MX record content is invalid")
+	ErrSRVInvalid = errors.New("SRV record content is invalid")
+	ErrTXTInvalid = errors.New("TXT record content is invalid")
+)
+
+// MX holds the fields of an MX record's content.
+type MX struct {
+	Preference uint16
+	Exchange   string
+}
+
+// ParseMX parses the content of an MX record, e.g. "10 mail.example.com.".
+func ParseMX(content string) (MX, error) {
+	fields := strings.Fields(content)
+	if len(fields) != 2 {
+		return MX{}, errs.Wrap(ErrMXInvalid, fmt.Errorf("expected 2 fields, got %d", len(fields)))
+	}
+
+	preference, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return MX{}, errs.Wrap(ErrMXInvalid, err)
+	}
+
+	return MX{Preference: uint16(preference), Exchange: fields[1]}, nil
+}
+
+// String formats the MX as record content.
+func (m MX) String() string {
+	return fmt.Sprintf("%d %s", m.Preference, m.Exchange)
+}
+
+// SRV holds the fields of an SRV record's content.
+type SRV struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+// ParseSRV parses the content of an SRV record, e.g. "10 5 443 target.example.com.".
+func ParseSRV(content string) (SRV, error) {
+	fields := strings.Fields(content)
+	if len(fields) != 4 {
+		return SRV{}, errs.Wrap(ErrSRVInvalid, fmt.Errorf("expected 4 fields, got %d", len(fields)))
+	}
+
+	srv := SRV{Target: fields[3]}
+	for idx, value := range []*uint16{&srv.Priority, &srv.Weight, &srv.Port} {
+		parsed, err := strconv.ParseUint(fields[idx], 10, 16)
+		if err != nil {
+			return SRV{}, errs.Wrap(ErrSRVInvalid, err)
+		}
+		*value = uint16(parsed)
+	}
+	return srv, nil
+}
+
+// String formats the SRV as record content.
+func (s SRV) String() string {
+	return fmt.Sprintf("%d %d %d %s", s.Priority, s.Weight, s.Port, s.Target)
+}
+
+// maxTXTStringLength is the longest character-string a TXT record can hold. Longer values are split into several.
+const maxTXTStringLength = 255
+
+// FormatTXT formats s as TXT record content: quoted, with quotes, backslashes and non-printable bytes escaped, and
+// split into several strings if it is longer than 255 octets.
+func FormatTXT(s string) string {
+	chunks := []string{}
+	for len(s) > maxTXTStringLength {
+		chunks = append(chunks, quoteTXTString(s[:maxTXTStringLength]))
+		s = s[maxTXTStringLength:]
+	}
+	chunks = append(chunks, quoteTXTString(s))
+	return strings.Join(chunks, " ")
+}
+
+// quoteTXTString quotes and escapes a single character-string.
+func quoteTXTString(s string) string {
+	quoted := strings.Builder{}
+	quoted.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '"' || ch == '\\':
+			quoted.WriteByte('\\')
+			quoted.WriteByte(ch)
+		case ch < ' ' || ch > '~':
+			fmt.Fprintf(&quoted, "\\%03d", ch)
+		default:
+			quoted.WriteByte(ch)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+// ParseTXT returns the value of TXT record content, concatenating its strings and removing quotes and escapes. It
+// is the inverse of FormatTXT. Unquoted strings are accepted, and whitespace between strings is ignored. A decimal
+// escape must be exactly three digits with a value no greater than 255, as in RFC 1035.
+func ParseTXT(content string) (string, error) {
+	value := strings.Builder{}
+	inQuotes := false
+	for i := 0; i < len(content); i++ {
+		ch := content[i]
+		switch {
+		case ch == '\\' && i+1 < len(content) && isDigits(content[i+1:i+2]):
+			if i+3 >= len(content) || !isDigits(content[i+1:i+4]) {
+				return "", errs.Wrap(ErrTXTInvalid, fmt.Errorf("decimal escape at offset %d is not 3 digits", i))
+			}
+			code, err := strconv.ParseUint(content[i+1:i+4], 10, 8)
+			if err != nil {
+				return "", errs.Wrap(ErrTXTInvalid, err)
+			}
+			value.WriteByte(byte(code))
+			i += 3
+		case ch == '\\' && i+1 < len(content):
+			i++
+			value.WriteByte(content[i])
+		case ch == '\\':
+			return "", errs.Wrap(ErrTXTInvalid, errors.New("content ends with an unterminated escape"))
+		case ch == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && (ch == ' ' || ch == '\t'):
+		default:
+			value.WriteByte(ch)
+		}
+	}
+	return value.String(), nil
+}
+
+// isDigits returns true if s is entirely ASCII digits.
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package records_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/errwrap"
+	. "gopkg.in/check.v1"
+
+	. "github.com/wrouesnel/go.powerdns/pdnstypes/records"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type RecordsSuite struct{}
+
+var _ = Suite(&RecordsSuite{})
+
+func (r *RecordsSuite) TestMX(c *C) {
+	mx, err := ParseMX("10 mail.example.com.")
+	c.Assert(err, IsNil)
+	c.Assert(mx, DeepEquals, MX{Preference: 10, Exchange: "mail.example.com."})
+	c.Assert(mx.String(), Equals, "10 mail.example.com.")
+
+	// Null MX (RFC7505)
+	mx, err = ParseMX("0 .")
+	c.Assert(err, IsNil)
+	c.Assert(mx.String(), Equals, "0 .")
+
+	for _, invalid := range []string{"", "10", "mail.example.com. 10", "65536 mail.example.com.", "10 a. b."} {
+		_, err := ParseMX(invalid)
+		c.Check(errwrap.Contains(err, ErrMXInvalid.Error()), Equals, true, Commentf("%q", invalid))
+	}
+}
+
+func (r *RecordsSuite) TestSRV(c *C) {
+	srv, err := ParseSRV("10 5 443 target.example.com.")
+	c.Assert(err, IsNil)
+	c.Assert(srv, DeepEquals, SRV{Priority: 10, Weight: 5, Port: 443, Target: "target.example.com."})
+	c.Assert(srv.String(), Equals, "10 5 443 target.example.com.")
+
+	for _, invalid := range []string{"", "10 5 443", "10 5 70000 target.", "10 -5 443 target.", "a b c d"} {
+		_, err := ParseSRV(invalid)
+		c.Check(errwrap.Contains(err, ErrSRVInvalid.Error()), Equals, true, Commentf("%q", invalid))
+	}
+}
+
+func (r *RecordsSuite) TestTXT(c *C) {
+	c.Assert(FormatTXT("v=spf1 -all"), Equals, `"v=spf1 -all"`)
+	c.Assert(FormatTXT(`say "hi" \o/`), Equals, `"say \"hi\" \\o/"`)
+	c.Assert(FormatTXT("tab\there"), Equals, `"tab\009here"`)
+	c.Assert(FormatTXT(""), Equals, `""`)
+
+	// Long values are split into 255 octet strings
+	long := strings.Repeat("a", 300)
+	formatted := FormatTXT(long)
+	c.Assert(formatted, Equals, `"`+strings.Repeat("a", 255)+`" "`+strings.Repeat("a", 45)+`"`)
+
+	for _, value := range []string{"v=spf1 -all", `say "hi" \o/`, "tab\there", "", long, "caf\xc3\xa9"} {
+		parsed, err := ParseTXT(FormatTXT(value))
+		c.Check(err, IsNil)
+		c.Check(parsed, Equals, value)
+	}
+
+	// Content from other sources
+	parsed, err := ParseTXT(`"v=DKIM1; k=rsa; " "p=MIGf"`)
+	c.Assert(err, IsNil)
+	c.Assert(parsed, Equals, "v=DKIM1; k=rsa; p=MIGf")
+	parsed, err = ParseTXT(`unquoted`)
+	c.Assert(err, IsNil)
+	c.Assert(parsed, Equals, "unquoted")
+	parsed, err = ParseTXT(`"\255\000\q"`)
+	c.Assert(err, IsNil)
+	c.Assert(parsed, Equals, "\xff\x00q")
+
+	for _, invalid := range []string{`"\256"`, `"\999"`, `"\9"`, `"\12x"`, `"\1`, `"trailing\`} {
+		_, err := ParseTXT(invalid)
+		c.Check(errwrap.Contains(err, ErrTXTInvalid.Error()), Equals, true, Commentf("%q", invalid))
+	}
+}
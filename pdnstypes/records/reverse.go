@@ -0,0 +1,67 @@
+package records
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/wrouesnel/go.powerdns/internal/errs"
+)
+
+// nolint: golint
+var (
+	ErrAddressInvalid = errors.New("IP address is invalid")
+	ErrCIDRInvalid    = errors.New("CIDR network is invalid")
+)
+
+const hexDigits = "0123456789abcdef"
+
+// ReversePTRName returns the name of the PTR record for an IPv4 or IPv6 address, e.g. "1.2.0.192.in-addr.arpa."
+// for "192.0.2.1". IPv6 names are made of the address's nibbles in reverse order, under "ip6.arpa.".
+func ReversePTRName(ip string) (string, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return "", errs.Wrap(ErrAddressInvalid, fmt.Errorf("%q", ip))
+	}
+
+	if strings.Contains(ip, ":") {
+		return reverseName(addr.To16(), 8*net.IPv6len), nil
+	}
+	return reverseName(addr.To4(), 8*net.IPv4len), nil
+}
+
+// ReverseZoneName returns the name of the reverse zone for an IPv4 or IPv6 network in CIDR notation, e.g.
+// "2.0.192.in-addr.arpa." for "192.0.2.0/24". Reverse zones can only be delegated on label boundaries, so a prefix
+// length which is not a multiple of 8 for IPv4 or of 4 for IPv6 gives the zone of the enclosing network, e.g.
+// "2.0.192.in-addr.arpa." for "192.0.2.64/26" too. Host bits set in cidr are ignored.
+func ReverseZoneName(cidr string) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", errs.Wrap(ErrCIDRInvalid, err)
+	}
+
+	ones, _ := network.Mask.Size()
+	return reverseName(network.IP, ones), nil
+}
+
+// reverseName returns the reverse DNS name of the first prefixLen bits of ip, which must be 4 or 16 bytes long.
+// prefixLen is rounded down to a label boundary.
+func reverseName(ip net.IP, prefixLen int) string {
+	labels := []string{}
+	if len(ip) == net.IPv4len {
+		for idx := prefixLen/8 - 1; idx >= 0; idx-- {
+			labels = append(labels, fmt.Sprintf("%d", ip[idx]))
+		}
+		return strings.Join(append(labels, "in-addr.arpa."), ".")
+	}
+
+	for nibble := prefixLen/4 - 1; nibble >= 0; nibble-- {
+		value := ip[nibble/2]
+		if nibble%2 == 0 {
+			value >>= 4
+		}
+		labels = append(labels, string(hexDigits[value&0xf]))
+	}
+	return strings.Join(append(labels, "ip6.arpa."), ".")
+}
@@ -0,0 +1,48 @@
+package records_test
+
+import (
+	"github.com/hashicorp/errwrap"
+	. "gopkg.in/check.v1"
+
+	. "github.com/wrouesnel/go.powerdns/pdnstypes/records"
+)
+
+func (r *RecordsSuite) TestReversePTRName(c *C) {
+	for ip, expected := range map[string]string{
+		"192.0.2.1":          "1.2.0.192.in-addr.arpa.",
+		"::ffff:192.0.2.1":   "1.0.2.0.0.0.0.c.f.f.f.f.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.ip6.arpa.",
+		"2001:db8::567:89ab": "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	} {
+		name, err := ReversePTRName(ip)
+		c.Assert(err, IsNil)
+		c.Check(name, Equals, expected, Commentf("%s", ip))
+	}
+
+	for _, invalid := range []string{"", "192.0.2", "192.0.2.0/24", "2001:db8::g"} {
+		_, err := ReversePTRName(invalid)
+		c.Check(errwrap.Contains(err, ErrAddressInvalid.Error()), Equals, true, Commentf("%q", invalid))
+	}
+}
+
+func (r *RecordsSuite) TestReverseZoneName(c *C) {
+	for cidr, expected := range map[string]string{
+		"192.0.2.0/24":     "2.0.192.in-addr.arpa.",
+		"192.0.2.64/26":    "2.0.192.in-addr.arpa.",
+		"10.1.2.3/8":       "10.in-addr.arpa.",
+		"192.0.2.1/32":     "1.2.0.192.in-addr.arpa.",
+		"0.0.0.0/0":        "in-addr.arpa.",
+		"2001:db8::/32":    "8.b.d.0.1.0.0.2.ip6.arpa.",
+		"2001:db8:f0::/44": "f.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+		"2001:db8:f0::/46": "f.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+		"2001:db8::1/128":  "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	} {
+		name, err := ReverseZoneName(cidr)
+		c.Assert(err, IsNil)
+		c.Check(name, Equals, expected, Commentf("%s", cidr))
+	}
+
+	for _, invalid := range []string{"", "192.0.2.0", "192.0.2.0/33", "2001:db8::/129"} {
+		_, err := ReverseZoneName(invalid)
+		c.Check(errwrap.Contains(err, ErrCIDRInvalid.Error()), Equals, true, Commentf("%q", invalid))
+	}
+}
@@ -0,0 +1,280 @@
+package records
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/wrouesnel/go.powerdns/internal/errs"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+// ErrZoneFileInvalid is returned (wrapped) when a zonefile cannot be parsed. The wrapped error includes the line.
+var ErrZoneFileInvalid = errors.New("Zonefile is invalid") // nolint: golint
+
+// nameFields lists the rdata fields of each record type which hold domain names, and so must be made fully
+// qualified since PowerDNS does not accept relative names in record content.
+var nameFields = map[string][]int{
+	"CNAME": {0},
+	"DNAME": {0},
+	"NS":    {0},
+	"PTR":   {0},
+	"MX":    {1},
+	"SRV":   {3},
+	"SOA":   {0, 1},
+}
+
+// ParseZoneFile parses an RFC1035 master file, such as the output of ExportZone, into RRsets. Relative names are
+// qualified with origin, which $ORIGIN directives may change. $INCLUDE is not supported. Only the IN class is
+// supported. Records are grouped into RRsets with shared.GroupRecords.
+func ParseZoneFile(r io.Reader, origin string) (shared.RRsets, error) {
+	p := zoneFileParser{origin: strings.TrimSuffix(origin, ".") + "."}
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	entryLine := 0
+	depth := 0
+	blankOwner := false
+	var tokens []string
+
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		// Entries may span lines within parentheses. The owner is only omitted if the first line is indented.
+		if depth == 0 {
+			entryLine = lineNum
+			blankOwner = strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+		}
+
+		lineTokens, newDepth, err := tokenizeZoneFileLine(line, depth)
+		if err != nil {
+			return nil, errs.Wrap(ErrZoneFileInvalid, fmt.Errorf("line %d: %v", lineNum, err))
+		}
+		tokens = append(tokens, lineTokens...)
+		depth = newDepth
+
+		if depth > 0 || len(tokens) == 0 {
+			continue
+		}
+
+		if err := p.entry(tokens, blankOwner); err != nil {
+			return nil, errs.Wrap(ErrZoneFileInvalid, fmt.Errorf("line %d: %v", entryLine, err))
+		}
+		tokens = nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if depth > 0 {
+		return nil, errs.Wrap(ErrZoneFileInvalid, fmt.Errorf("line %d: unclosed parenthesis", entryLine))
+	}
+
+	return shared.GroupRecords(p.records), nil
+}
+
+// tokenizeZoneFileLine splits a line into whitespace separated tokens, dropping comments and parentheses. Quoted
+// strings are kept intact, quotes included. depth is the parenthesis nesting at the start of the line, and the
+// nesting at the end is returned.
+func tokenizeZoneFileLine(line string, depth int) ([]string, int, error) {
+	tokens := []string{}
+	token := strings.Builder{}
+	inQuotes := false
+
+	endToken := func() {
+		if token.Len() > 0 {
+			tokens = append(tokens, token.String())
+			token.Reset()
+		}
+	}
+
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case ch == '\\' && i+1 < len(line):
+			// Escapes are passed through as-is for the server to interpret.
+			token.WriteByte(ch)
+			i++
+			token.WriteByte(line[i])
+		case ch == '"':
+			token.WriteByte(ch)
+			inQuotes = !inQuotes
+		case inQuotes:
+			token.WriteByte(ch)
+		case ch == ';':
+			i = len(line)
+		case ch == '(':
+			endToken()
+			depth++
+		case ch == ')':
+			endToken()
+			depth--
+			if depth < 0 {
+				return nil, depth, errors.New("unbalanced parenthesis")
+			}
+		case ch == ' ' || ch == '\t':
+			endToken()
+		default:
+			token.WriteByte(ch)
+		}
+	}
+
+	if inQuotes {
+		return nil, depth, errors.New("unterminated quoted string")
+	}
+	endToken()
+
+	return tokens, depth, nil
+}
+
+// zoneFileParser holds the state carried between zonefile entries.
+type zoneFileParser struct {
+	origin     string
+	defaultTTL *uint32
+	lastTTL    *uint32
+	lastOwner  string
+	records    []shared.NamedRecord
+}
+
+// entry processes the tokens of a single directive or record.
+func (p *zoneFileParser) entry(tokens []string, blankOwner bool) error {
+	switch directive := strings.ToUpper(tokens[0]); directive {
+	case "$ORIGIN":
+		if len(tokens) != 2 {
+			return errors.New("$ORIGIN requires a single domain name")
+		}
+		p.origin = p.qualify(tokens[1])
+		return nil
+	case "$TTL":
+		if len(tokens) != 2 {
+			return errors.New("$TTL requires a single TTL")
+		}
+		ttl, err := parseTTL(tokens[1])
+		if err != nil {
+			return err
+		}
+		p.defaultTTL = &ttl
+		return nil
+	default:
+		if strings.HasPrefix(directive, "$") {
+			return fmt.Errorf("unsupported directive %s", tokens[0])
+		}
+	}
+
+	owner := p.lastOwner
+	if !blankOwner {
+		owner = p.qualify(tokens[0])
+		tokens = tokens[1:]
+	}
+	if owner == "" {
+		return errors.New("record has no owner name")
+	}
+	p.lastOwner = owner
+
+	// The TTL and class are both optional, and may appear in either order.
+	var ttl *uint32
+	for len(tokens) > 0 {
+		if strings.ToUpper(tokens[0]) == "IN" {
+			tokens = tokens[1:]
+			continue
+		}
+		if ttl != nil {
+			break
+		}
+		parsed, err := parseTTL(tokens[0])
+		if err != nil {
+			break
+		}
+		ttl = &parsed
+		tokens = tokens[1:]
+	}
+
+	if len(tokens) < 2 {
+		return errors.New("record has no type or data")
+	}
+
+	switch {
+	case ttl != nil:
+		p.lastTTL = ttl
+	case p.defaultTTL != nil:
+		ttl = p.defaultTTL
+	case p.lastTTL != nil:
+		ttl = p.lastTTL
+	default:
+		return errors.New("record has no TTL and no $TTL is set")
+	}
+
+	rrtype := strings.ToUpper(tokens[0])
+	rdata := tokens[1:]
+	for _, idx := range nameFields[rrtype] {
+		if idx < len(rdata) {
+			rdata[idx] = p.qualify(rdata[idx])
+		}
+	}
+
+	p.records = append(p.records, shared.NamedRecord{
+		Name:    owner,
+		Type:    rrtype,
+		TTL:     *ttl,
+		Content: strings.Join(rdata, " "),
+	})
+	return nil
+}
+
+// qualify makes a name fully qualified relative to the current origin.
+func (p *zoneFileParser) qualify(name string) string {
+	switch {
+	case name == "@":
+		return p.origin
+	case strings.HasSuffix(name, "."):
+		return name
+	case p.origin == ".":
+		return name + "."
+	default:
+		return name + "." + p.origin
+	}
+}
+
+// ttlUnits are the multipliers of the BIND TTL unit suffixes.
+var ttlUnits = map[byte]uint64{
+	's': 1,
+	'm': 60,
+	'h': 60 * 60,
+	'd': 24 * 60 * 60,
+	'w': 7 * 24 * 60 * 60,
+}
+
+// parseTTL parses a TTL in seconds, or in the BIND unit format (e.g. "1h30m").
+func parseTTL(value string) (uint32, error) {
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return uint32(seconds), nil
+	}
+
+	var total, current uint64
+	digits := false
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		if '0' <= ch && ch <= '9' {
+			current = current*10 + uint64(ch-'0')
+			digits = true
+			continue
+		}
+		unit, found := ttlUnits[ch|0x20]
+		if !found || !digits {
+			return 0, fmt.Errorf("invalid TTL %q", value)
+		}
+		total += current * unit
+		current = 0
+		digits = false
+	}
+
+	if digits || value == "" || total > 1<<32-1 {
+		return 0, fmt.Errorf("invalid TTL %q", value)
+	}
+	return uint32(total), nil
+}
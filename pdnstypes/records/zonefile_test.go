@@ -0,0 +1,92 @@
+package records_test
+
+import (
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	. "gopkg.in/check.v1"
+
+	. "github.com/wrouesnel/go.powerdns/pdnstypes/records"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+const testZoneFile = `$ORIGIN example.com.
+$TTL 1h
+@	IN	SOA	ns1 hostmaster (
+		2024010101 ; serial
+		10800      ; refresh
+		3600       ; retry
+		604800     ; expire
+		3600 )     ; minimum
+	IN	NS	ns1
+	IN	NS	ns2.example.net.
+ns1	300	IN	A	192.0.2.1
+www		A	192.0.2.2
+	IN 60	A	192.0.2.3
+mail	IN	MX	10 mx1
+	IN	MX	20 mx2.example.net.
+txt	IN	TXT	"v=spf1 -all; not a comment" "second \" string"
+_sip._tcp	SRV	10 5 5060 sip
+$ORIGIN sub.example.com.
+host	CNAME	www.example.com.
+alias	CNAME	host
+`
+
+func (r *RecordsSuite) TestParseZoneFile(c *C) {
+	rrsets, err := ParseZoneFile(strings.NewReader(testZoneFile), "example.com")
+	c.Assert(err, IsNil)
+
+	expected := shared.RRsets{
+		{Name: "example.com.", Type: "SOA", TTL: 3600, Records: shared.Records{
+			{Content: "ns1.example.com. hostmaster.example.com. 2024010101 10800 3600 604800 3600"},
+		}},
+		{Name: "example.com.", Type: "NS", TTL: 3600, Records: shared.Records{
+			{Content: "ns1.example.com."}, {Content: "ns2.example.net."},
+		}},
+		{Name: "ns1.example.com.", Type: "A", TTL: 300, Records: shared.Records{{Content: "192.0.2.1"}}},
+		// The second record's TTL is lower, so the RRset takes it.
+		{Name: "www.example.com.", Type: "A", TTL: 60, Records: shared.Records{
+			{Content: "192.0.2.2"}, {Content: "192.0.2.3"},
+		}},
+		{Name: "mail.example.com.", Type: "MX", TTL: 3600, Records: shared.Records{
+			{Content: "10 mx1.example.com."}, {Content: "20 mx2.example.net."},
+		}},
+		{Name: "txt.example.com.", Type: "TXT", TTL: 3600, Records: shared.Records{
+			{Content: `"v=spf1 -all; not a comment" "second \" string"`},
+		}},
+		{Name: "_sip._tcp.example.com.", Type: "SRV", TTL: 3600, Records: shared.Records{
+			{Content: "10 5 5060 sip.example.com."},
+		}},
+		{Name: "host.sub.example.com.", Type: "CNAME", TTL: 3600, Records: shared.Records{
+			{Content: "www.example.com."},
+		}},
+		{Name: "alias.sub.example.com.", Type: "CNAME", TTL: 3600, Records: shared.Records{
+			{Content: "host.sub.example.com."},
+		}},
+	}
+	c.Assert(rrsets, DeepEquals, expected)
+}
+
+func (r *RecordsSuite) TestParseZoneFileLastTTL(c *C) {
+	// Without $TTL, records without a TTL take the last one given.
+	rrsets, err := ParseZoneFile(strings.NewReader("a 1d A 192.0.2.1\nb A 192.0.2.2\n"), "example.com.")
+	c.Assert(err, IsNil)
+	c.Assert(len(rrsets), Equals, 2)
+	c.Assert(rrsets[1].TTL, Equals, uint32(86400))
+}
+
+func (r *RecordsSuite) TestParseZoneFileInvalid(c *C) {
+	for _, invalid := range []string{
+		"a A 192.0.2.1\n",
+		"$TTL 60\na IN SOA ns1 hostmaster ( 1 2 3 4 5\n",
+		"$TTL 60\na IN A 192.0.2.1 )\n",
+		"$TTL 60\na IN TXT \"unterminated\n",
+		"$INCLUDE other.zone\n",
+		"$TTL 1x\n",
+		"$TTL 60\n\tIN A 192.0.2.1\n",
+		"$TTL 60\na IN A\n",
+	} {
+		_, err := ParseZoneFile(strings.NewReader(invalid), "example.com.")
+		c.Check(errwrap.Contains(err, ErrZoneFileInvalid.Error()), Equals, true, Commentf("%q", invalid))
+	}
+}
@@ -18,6 +18,7 @@ const (
 // Zone implements the recusor nameserver zone subtype.
 type Zone struct {
 	shared.Zone
+	Kind             Kind     `json:"kind"`
 	Servers          []string `json:"servers"`
 	RecursionDesired bool     `json:"recursion_desired"`
 }
@@ -26,20 +27,25 @@ type Zone struct {
 // i.e. it does not compare RRsets or serials.
 func (z *Zone) HeaderEquals(a Zone) bool {
 	return z.Zone.HeaderEquals(a.Zone) &&
+		z.Kind == a.Kind &&
 		reflect.DeepEqual(z.Servers, a.Servers) &&
 		z.RecursionDesired == a.RecursionDesired
 }
 
-// Equals does a HeaderCompare and checks if the contained zones are exactly early
+// Equals does a HeaderCompare and checks if the contained zones are exactly equal
 func (z *Zone) Equals(a Zone) bool {
-	return z.Zone.HeaderEquals(a.Zone) && z.Zone.Equals(a.Zone)
+	return z.HeaderEquals(a) && z.Zone.Equals(a.Zone)
 }
 
 // Copy makes a value based copy of the zone
 func (z *Zone) Copy() Zone {
 	r := Zone{}
 	r.Zone = z.Zone.Copy()
-	r.Servers = z.Servers[:]
+	r.Kind = z.Kind
+	if z.Servers != nil {
+		r.Servers = make([]string, len(z.Servers))
+		copy(r.Servers, z.Servers)
+	}
 	r.RecursionDesired = z.RecursionDesired
 	return r
 }
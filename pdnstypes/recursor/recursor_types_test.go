@@ -21,6 +21,7 @@ var _ = Suite(&RecTypeSuite{})
 func (r *RecTypeSuite) TestZone(c *C) {
 	z := Zone{
 		Zone:             testutil.MakeZone(),
+		Kind:             KindForwarded,
 		Servers:          testutil.MakeRandIPList(10),
 		RecursionDesired: rand.Intn(1) == 1,
 	}
@@ -38,4 +39,41 @@ func (r *RecTypeSuite) TestZone(c *C) {
 	zCopy.Name = "something else"
 	c.Assert(z.HeaderEquals(zCopy), Equals, false)
 	c.Assert(z.Equals(zCopy), Equals, false)
+
+	// kind is part of the header
+	zCopy = z.Copy()
+	c.Assert(zCopy.Kind, Equals, KindForwarded)
+	zCopy.Kind = KindNative
+	c.Assert(z.HeaderEquals(zCopy), Equals, false)
+}
+
+func (r *RecTypeSuite) TestZoneEqualsServers(c *C) {
+	z := Zone{
+		Zone:    testutil.MakeZone(),
+		Kind:    KindForwarded,
+		Servers: []string{"192.0.2.1"},
+	}
+
+	zOther := z.Copy()
+	zOther.Servers = []string{"192.0.2.2"}
+	c.Assert(z.Equals(zOther), Equals, false)
+
+	zOther.Servers = []string{"192.0.2.1"}
+	zOther.RecursionDesired = !z.RecursionDesired
+	c.Assert(z.Equals(zOther), Equals, false)
+}
+
+func (r *RecTypeSuite) TestZoneCopyServers(c *C) {
+	z := Zone{
+		Zone:    testutil.MakeZone(),
+		Kind:    KindForwarded,
+		Servers: []string{"192.0.2.1", "192.0.2.2"},
+	}
+
+	zCopy := z.Copy()
+	zCopy.Servers[0] = "192.0.2.3"
+	c.Assert(z.Servers, DeepEquals, []string{"192.0.2.1", "192.0.2.2"})
+
+	z.Servers = nil
+	c.Assert(z.Copy().Servers, IsNil)
 }
@@ -1,8 +1,16 @@
 package shared
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/wrouesnel/go.powerdns/internal/errs"
 )
 
 // Error struct
@@ -25,6 +33,50 @@ func (e Error) WrappedErrors() []error {
 	return ret
 }
 
+// errorJSON is the wire format of an Error. PowerDNS sends the details of an error, e.g. each invalid RRset of a
+// 422 response, as a list of strings, so the entries of errors are decoded separately.
+type errorJSON struct {
+	Message string            `json:"error"`
+	Errors  []json.RawMessage `json:"errors,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The entries of errors may be strings, as PowerDNS sends, or objects.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	raw := errorJSON{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	result := Error{Message: raw.Message}
+	for _, entry := range raw.Errors {
+		nested := Error{}
+		if trimmed := bytes.TrimSpace(entry); len(trimmed) > 0 && trimmed[0] == '"' {
+			if err := json.Unmarshal(trimmed, &nested.Message); err != nil {
+				return err
+			}
+		} else if err := json.Unmarshal(entry, &nested); err != nil {
+			return err
+		}
+		result.Errors = append(result.Errors, nested)
+	}
+
+	*e = result
+	return nil
+}
+
+// MarshalJSON encodes an Error in the same shape the server sends, with errors as a list of the nested messages.
+func (e Error) MarshalJSON() ([]byte, error) {
+	raw := errorJSON{Message: e.Message}
+	for _, nested := range e.Errors {
+		message, err := json.Marshal(nested.Message)
+		if err != nil {
+			return nil, err
+		}
+		raw.Errors = append(raw.Errors, message)
+	}
+	return json.Marshal(raw)
+}
+
 // APIVersion struct
 type APIVersion struct {
 	URL     string `json:"url"`
@@ -51,6 +103,53 @@ type ServerInfo struct {
 	ZonesURL   string     `json:"zones_url"`
 }
 
+// ErrServerVersionInvalid is returned (wrapped) when a server version cannot be parsed.
+var ErrServerVersionInvalid = errors.New("Server version is not of the form major.minor.patch") // nolint: golint
+
+// SemVer parses the server's version, e.g. "4.7.3" or "4.8.0-alpha1". Pre-release and build suffixes are ignored,
+// and a missing patch version is taken as zero.
+func (s ServerInfo) SemVer() (major, minor, patch int, err error) {
+	version := s.Version
+	if idx := strings.IndexAny(version, "-+"); idx != -1 {
+		version = version[:idx]
+	}
+
+	parts := strings.SplitN(version, ".", 4)
+	if len(parts) < 2 {
+		return 0, 0, 0, errs.Wrap(ErrServerVersionInvalid, fmt.Errorf("version %q", s.Version))
+	}
+	if len(parts) == 2 {
+		parts = append(parts, "0")
+	}
+
+	numbers := make([]int, 3)
+	for idx := range numbers {
+		numbers[idx], err = strconv.Atoi(parts[idx])
+		if err != nil || numbers[idx] < 0 {
+			return 0, 0, 0, errs.Wrap(ErrServerVersionInvalid, fmt.Errorf("version %q", s.Version))
+		}
+	}
+	return numbers[0], numbers[1], numbers[2], nil
+}
+
+// ActionResult is returned by actions which only report a human readable outcome.
+type ActionResult struct {
+	Result string `json:"result"`
+}
+
+// CacheFlushResult struct
+type CacheFlushResult struct {
+	Count  int    `json:"count"`
+	Result string `json:"result"`
+}
+
+// ConfigSetting struct
+type ConfigSetting struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
 // Zone implements the common set of fields for authoritative and recursor zones.
 // It needs to be inherited to work with the API, generally.
 type Zone struct {
@@ -66,6 +165,54 @@ type Zone struct {
 	RRsets RRsets `json:"rrsets"`
 }
 
+// nolint: golint
+var (
+	ErrZoneNameEmpty        = errors.New("Zone name is empty")
+	ErrZoneNameEmptyLabel   = errors.New("Zone name contains an empty label")
+	ErrZoneNameLabelTooLong = errors.New("Zone name contains a label longer than 63 octets")
+	ErrZoneNameTooLong      = errors.New("Zone name is longer than 255 octets")
+	maxZoneNameLabelLength  = 63
+	maxZoneNameLength       = 255
+)
+
+// Normalize converts the zone name to the canonical form PowerDNS requires: lowercase, with a trailing dot.
+func (z *Zone) Normalize() {
+	z.Name = strings.ToLower(z.Name)
+	if !strings.HasSuffix(z.Name, ".") {
+		z.Name += "."
+	}
+}
+
+// Validate checks the zone name is a valid domain name. It does not require the name to be normalized.
+func (z *Zone) Validate() error {
+	if z.Name == "" {
+		return ErrZoneNameEmpty
+	}
+
+	name := strings.TrimSuffix(z.Name, ".")
+	if name == "" {
+		// The root zone
+		return nil
+	}
+
+	// The wire format is a length octet per label, the labels, and a terminating zero octet.
+	wireLength := 1
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 {
+			return ErrZoneNameEmptyLabel
+		}
+		if len(label) > maxZoneNameLabelLength {
+			return ErrZoneNameLabelTooLong
+		}
+		wireLength += len(label) + 1
+	}
+
+	if wireLength > maxZoneNameLength {
+		return ErrZoneNameTooLong
+	}
+	return nil
+}
+
 // HeaderEquals compares static zone header information only. It ignores RRsets, Type, URL
 func (z *Zone) HeaderEquals(a Zone) bool {
 	return z.Name == a.Name
@@ -86,12 +233,18 @@ func (z *Zone) Copy() Zone {
 // RRsets implements a collection of RRsets to allow helper methods
 type RRsets []RRset
 
-// Equals returns whether the contents (names, TTLS, records) of the contained RRset equal those of b.
+// Equals returns whether the contents (names, TTLS, records) of the contained RRset equal those of b, without
+// regard to order.
 func (rr RRsets) Equals(b RRsets) bool {
+	ourMap := rr.ToMap()
 	bMap := b.ToMap()
 
-	for _, ourv := range rr {
-		therev, found := bMap[ourv.UniqueName()]
+	if len(ourMap) != len(bMap) {
+		return false
+	}
+
+	for k, ourv := range ourMap {
+		therev, found := bMap[k]
 		if !found {
 			return false
 		}
@@ -123,15 +276,80 @@ func (rrs RRsets) ToMap() map[RRsetUniqueName]RRset {
 	return r
 }
 
+// uniqueNames returns the unique names of the RRsets in order of first appearance.
+func (rrs RRsets) uniqueNames() []RRsetUniqueName {
+	result := make([]RRsetUniqueName, 0, len(rrs))
+	seen := make(map[RRsetUniqueName]struct{}, len(rrs))
+	for _, rr := range rrs {
+		k := rr.UniqueName()
+		if _, found := seen[k]; !found {
+			seen[k] = struct{}{}
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+// Sort sorts the RRsets in place by name, then type. The records of each RRset are not sorted.
+func (rrs RRsets) Sort() {
+	sort.SliceStable(rrs, func(i, j int) bool {
+		if rrs[i].Name != rrs[j].Name {
+			return rrs[i].Name < rrs[j].Name
+		}
+		return rrs[i].Type < rrs[j].Type
+	})
+}
+
+// Dedup removes duplicate records from each RRset in place. PowerDNS rejects PATCHes with duplicate records in an
+// RRset, which can arise when RRsets are assembled from several sources.
+func (rrs RRsets) Dedup() {
+	for idx := range rrs {
+		rrs[idx].Dedup()
+	}
+}
+
+// FilterByType returns copies of the RRsets which have one of the given types, e.g. "MX". Types are compared
+// case-insensitively.
+func (rrs RRsets) FilterByType(types ...string) RRsets {
+	result := RRsets{}
+	for _, rr := range rrs {
+		for _, rrtype := range types {
+			if strings.EqualFold(rr.Type, rrtype) {
+				result = append(result, rr.Copy())
+				break
+			}
+		}
+	}
+	return result
+}
+
+// FilterByName returns copies of the RRsets named suffix or any name under it, e.g. "_acme-challenge.example.com."
+// matches itself and "www._acme-challenge.example.com." but not "x_acme-challenge.example.com.". Names are compared
+// case-insensitively, and a missing trailing dot is ignored.
+func (rrs RRsets) FilterByName(suffix string) RRsets {
+	suffix = strings.ToLower(strings.TrimSuffix(suffix, "."))
+	result := RRsets{}
+	for _, rr := range rrs {
+		name := strings.ToLower(strings.TrimSuffix(rr.Name, "."))
+		if suffix == "" || name == suffix || strings.HasSuffix(name, "."+suffix) {
+			result = append(result, rr.Copy())
+		}
+	}
+	return result
+}
+
 // Difference returns RRsets which are in this RRset but not in b down to the Record level.
 // i.e. two identical RRs with different records will result in that RR being included in the
-// result with only those records missing from this RRset.
+// result with only those records missing from this RRset. RRsets are returned in the order they appear in this
+// collection. Only names, types and records are compared, so TTLs are ignored: this suits comparing desired RRsets
+// against those returned by the server, whose TTLs can drift by a few seconds as a side effect of SOA-EDIT.
 func (rrs RRsets) Difference(b RRsets) RRsets {
 	us := rrs.ToMap()
 	them := b.ToMap()
 	result := RRsets{}
 
-	for k, v := range us {
+	for _, k := range rrs.uniqueNames() {
+		v := us[k]
 		// If key missing entirely, add it...
 		if thereV, found := them[k]; !found {
 			result = append(result, v.Copy())
@@ -149,7 +367,7 @@ func (rrs RRsets) Difference(b RRsets) RRsets {
 			if hasDifferences {
 				diffrr := v.Copy()
 				diffrr.Records = recordDifferences
-				result = append(result, v.Copy())
+				result = append(result, diffrr)
 			}
 		}
 	}
@@ -157,19 +375,114 @@ func (rrs RRsets) Difference(b RRsets) RRsets {
 	return result
 }
 
+// EqualsIgnoringTTL returns whether both collections contain the same RRsets with the same records, without
+// regard to order or TTLs.
+func (rrs RRsets) EqualsIgnoringTTL(b RRsets) bool {
+	return len(rrs.Difference(b)) == 0 && len(b.Difference(rrs)) == 0
+}
+
+// CompareOptions relaxes the comparison made by RRsets.EqualsWithOptions. The zero value compares everything,
+// including order.
+type CompareOptions struct {
+	// IgnoreTTL ignores differences in RRset TTLs.
+	IgnoreTTL bool
+	// IgnoreOrder ignores the order of RRsets and of the records within them, and duplicate records.
+	IgnoreOrder bool
+	// CaseInsensitiveContent compares record contents ignoring case, since the server may lowercase hostnames.
+	CaseInsensitiveContent bool
+	// IgnoreDisabled ignores the disabled flag of records.
+	IgnoreDisabled bool
+}
+
+// recordKey returns the record as compared under opts.
+func (opts CompareOptions) recordKey(r Record) Record {
+	k := r.compareKey()
+	if opts.CaseInsensitiveContent {
+		k.Content = strings.ToLower(k.Content)
+	}
+	if opts.IgnoreDisabled {
+		k.Disabled = false
+	}
+	return k
+}
+
+// rrsetEquals compares two RRsets of the same name and type under opts.
+func (opts CompareOptions) rrsetEquals(a, b RRset) bool {
+	if !opts.IgnoreTTL && a.TTL != b.TTL {
+		return false
+	}
+
+	if !opts.IgnoreOrder {
+		if len(a.Records) != len(b.Records) {
+			return false
+		}
+		for idx := range a.Records {
+			if opts.recordKey(a.Records[idx]) != opts.recordKey(b.Records[idx]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	aKeys := map[Record]struct{}{}
+	for _, record := range a.Records {
+		aKeys[opts.recordKey(record)] = struct{}{}
+	}
+	bKeys := map[Record]struct{}{}
+	for _, record := range b.Records {
+		k := opts.recordKey(record)
+		if _, found := aKeys[k]; !found {
+			return false
+		}
+		bKeys[k] = struct{}{}
+	}
+	return len(aKeys) == len(bKeys)
+}
+
+// EqualsWithOptions returns whether this collection equals b, relaxing the comparison as set in opts. This allows
+// reconciliation to treat RRsets as equal when the server considers them so, despite its normalization.
+func (rrs RRsets) EqualsWithOptions(b RRsets, opts CompareOptions) bool {
+	if !opts.IgnoreOrder {
+		if len(rrs) != len(b) {
+			return false
+		}
+		for idx := range rrs {
+			if rrs[idx].UniqueName() != b[idx].UniqueName() || !opts.rrsetEquals(rrs[idx], b[idx]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	ourMap := rrs.ToMap()
+	theirMap := b.ToMap()
+	if len(ourMap) != len(theirMap) {
+		return false
+	}
+	for k, ours := range ourMap {
+		theirs, found := theirMap[k]
+		if !found || !opts.rrsetEquals(ours, theirs) {
+			return false
+		}
+	}
+	return true
+}
+
 // IsSubsetOf returns true if all RRsets in this collection are also in b. Differences in records even if they are
 // inclusive will cause this to return false.
 func (rrs RRsets) IsSubsetOf(b RRsets) bool {
 	return len(rrs.Difference(b)) == 0
 }
 
-// Intersection returns RRsets which are in this RRset and b down to the Record level.
+// Intersection returns RRsets which are in this RRset and b down to the Record level. RRsets whose TTLs differ
+// are not considered to intersect. RRsets are returned in the order they appear in this collection.
 func (rrs RRsets) Intersection(b RRsets) RRsets {
 	us := rrs.ToMap()
 	them := b.ToMap()
 	result := RRsets{}
 
-	for k, v := range us {
+	for _, k := range rrs.uniqueNames() {
+		v := us[k]
 		if thereV, found := them[k]; found {
 			if v.TTL != thereV.TTL {
 				continue
@@ -178,7 +491,7 @@ func (rrs RRsets) Intersection(b RRsets) RRsets {
 			intersectingRecords := v.Records.Intersection(thereV.Records)
 
 			intersectingRr := v.Copy()
-			v.Records = intersectingRecords
+			intersectingRr.Records = intersectingRecords
 
 			result = append(result, intersectingRr)
 		}
@@ -188,7 +501,7 @@ func (rrs RRsets) Intersection(b RRsets) RRsets {
 }
 
 // Merge returns the Union of this rrset with b. Where header fields conflict, they are resolved in favor of
-// this rrset.
+// this rrset. RRsets are returned in order of first appearance in this collection, then b.
 func (rrs RRsets) Merge(b RRsets) RRsets {
 	union := map[RRsetUniqueName]RRset{}
 
@@ -212,9 +525,42 @@ func (rrs RRsets) Merge(b RRsets) RRsets {
 
 	result := make(RRsets, 0, len(union))
 
-	for _, v := range union {
-		result = append(result, v)
+	all := make(RRsets, 0, len(rrs)+len(b))
+	for _, k := range append(append(all, rrs...), b...).uniqueNames() {
+		result = append(result, union[k])
+	}
+	return result
+}
+
+// NamedRecord is a single record together with the RRset header fields it belongs under. It is the flat form records
+// from external sources usually arrive in.
+type NamedRecord struct {
+	Name     string
+	Type     string
+	TTL      uint32
+	Content  string
+	Disabled bool
+}
+
+// GroupRecords coalesces records into RRsets by name and type, in order of first appearance. Where the records of
+// an RRset have different TTLs the lowest is used, as RFC2181 requires.
+func GroupRecords(records []NamedRecord) RRsets {
+	result := RRsets{}
+	indexes := make(map[RRsetUniqueName]int)
+
+	for _, record := range records {
+		uniqueName := RRsetUniqueName{record.Name, record.Type}
+		idx, found := indexes[uniqueName]
+		if !found {
+			idx = len(result)
+			indexes[uniqueName] = idx
+			result = append(result, RRset{Name: record.Name, Type: record.Type, TTL: record.TTL, Records: Records{}})
+		} else if record.TTL < result[idx].TTL {
+			result[idx].TTL = record.TTL
+		}
+		result[idx].Records = append(result[idx].Records, Record{Content: record.Content, Disabled: record.Disabled})
 	}
+
 	return result
 }
 
@@ -231,9 +577,12 @@ type RRset struct {
 	Type    string  `json:"type"`
 	TTL     uint32  `json:"ttl"`
 	Records Records `json:"records"`
+	// Comments are only replaced by a PATCH if some are supplied.
+	Comments []Comment `json:"comments,omitempty"`
 }
 
-// Equals checks whether this RRset exactly equals B (without worrying about things like Record ordering)
+// Equals checks whether this RRset exactly equals B (without worrying about things like Record ordering). Comments
+// are ignored.
 func (rr *RRset) Equals(b RRset) bool {
 	return rr.Name == b.Name && rr.Type == b.Type && rr.TTL == b.TTL && rr.Records.Equals(b.Records)
 }
@@ -242,6 +591,12 @@ func (rr *RRset) Equals(b RRset) bool {
 func (rr *RRset) Copy() RRset {
 	copy := *rr
 	copy.Records = rr.Records.Copy()
+	if rr.Comments != nil {
+		copy.Comments = make([]Comment, 0, len(rr.Comments))
+		for _, comment := range rr.Comments {
+			copy.Comments = append(copy.Comments, comment.Copy())
+		}
+	}
 
 	return copy
 }
@@ -253,6 +608,27 @@ func (rr *RRset) Merge(b RRset) RRset {
 	return result
 }
 
+// Dedup removes records with duplicate content from the RRset in place, keeping the first of each. PowerDNS
+// rejects an RRset with the same content twice even if only one copy is disabled, so the copies are merged: the
+// kept record is disabled only if every copy was.
+func (rr *RRset) Dedup() {
+	if rr.Records == nil {
+		return
+	}
+
+	indexes := make(map[string]int)
+	results := Records{}
+	for _, record := range rr.Records {
+		if idx, dup := indexes[record.Content]; dup {
+			results[idx].Disabled = results[idx].Disabled && record.Disabled
+			continue
+		}
+		indexes[record.Content] = len(results)
+		results = append(results, record.Copy())
+	}
+	rr.Records = results
+}
+
 // UniqueName returns a populated RRsetUniqueName for this RRset
 func (rr *RRset) UniqueName() RRsetUniqueName {
 	return RRsetUniqueName{
@@ -264,78 +640,68 @@ func (rr *RRset) UniqueName() RRsetUniqueName {
 // Records represents a collection of records.
 type Records []Record
 
-// ToMap returns the Records collections as a map of unique elements
+// ToMap returns the Records collections as a map of unique elements. Records are keyed by their compareKey, so
+// request-only fields such as SetPtr are not included in the keys.
 func (r Records) ToMap() map[Record]struct{} {
 	result := make(map[Record]struct{})
 	for _, v := range r {
-		result[v.Copy()] = struct{}{}
+		result[v.compareKey()] = struct{}{}
 	}
 	return result
 }
 
-// Equals returns true if this set of records contains exactly the same set as b
+// Equals returns true if this set of records contains exactly the same set as b, i.e. neither has records the
+// other lacks (see Difference).
 func (r Records) Equals(b Records) bool {
-	them := b.ToMap()
-	for _, ourv := range r {
-		_, found := them[ourv]
-		if !found {
-			return false
-		}
-	}
-	return true
+	return len(r.Difference(b)) == 0 && len(b.Difference(r)) == 0
 }
 
-// Difference returns the records which are in this Records collections but not in b.
-func (r Records) Difference(b Records) Records {
-	us := r.ToMap()
-	them := b.ToMap()
+// filter returns unique copies of the records in this collection for which the presence of their compareKey in
+// them equals want.
+func (r Records) filter(them map[Record]struct{}, want bool) Records {
+	seen := make(map[Record]struct{})
 	results := Records{}
 
-	for k := range us {
-		if _, found := them[k]; !found {
-			results = append(results, k.Copy())
+	for _, v := range r {
+		k := v.compareKey()
+		if _, found := them[k]; found != want {
+			continue
+		}
+		if _, dup := seen[k]; dup {
+			continue
 		}
+		seen[k] = struct{}{}
+		results = append(results, v.Copy())
 	}
 
 	return results
 }
 
+// Difference returns the records which are in this Records collections but not in b.
+func (r Records) Difference(b Records) Records {
+	return r.filter(b.ToMap(), false)
+}
+
 // Intersection returns the records which are in this Records collections and b.
 func (r Records) Intersection(b Records) Records {
-	us := r.ToMap()
-	them := b.ToMap()
-	results := Records{}
-
-	for k := range us {
-		if _, found := them[k]; found {
-			results = append(results, k.Copy())
-		}
-	}
-
-	return results
+	return r.filter(b.ToMap(), true)
 }
 
-// Union returns Records consisting of the merged contents of both Records collections.
+// Union returns Records consisting of the merged contents of both Records collections. Where a record is in both,
+// this collection's copy is kept.
 func (r Records) Union(b Records) Records {
-	us := r.ToMap()
-	them := b.ToMap()
-	union := make(map[Record]struct{})
-
-	for k := range us {
-		union[k] = struct{}{}
-	}
-
-	for k := range them {
-		union[k] = struct{}{}
-	}
-
-	results := Records{}
-
-	for k := range union {
-		results = append(results, k.Copy())
-	}
+	results := r.filter(map[Record]struct{}{}, false)
+	return append(results, b.filter(results.ToMap(), false)...)
+}
 
-	return results
+// Sort sorts the records in place by content, with enabled records before disabled ones of the same content.
+func (r Records) Sort() {
+	sort.SliceStable(r, func(i, j int) bool {
+		if r[i].Content != r[j].Content {
+			return r[i].Content < r[j].Content
+		}
+		return !r[i].Disabled && r[j].Disabled
+	})
 }
 
 // IsSubsetOf returns true if all records in this collection are also in b.
@@ -356,7 +722,9 @@ func (r Records) Copy() Records {
 type Record struct {
 	Content  string `json:"content"`
 	Disabled bool   `json:"disabled"`
-	SetPtr   bool   `json:"set-ptr"`
+	// SetPtr asks the server to create a matching PTR record. It is never returned by the server, and so is
+	// ignored when comparing records.
+	SetPtr bool `json:"set-ptr"`
 }
 
 // Copy makes a value-based copy of a Record
@@ -364,6 +732,56 @@ func (r *Record) Copy() Record {
 	return *r
 }
 
+// Equals returns true if this record is equal to b. SetPtr is ignored since it is a request-only directive which
+// the server never returns.
+func (r *Record) Equals(b Record) bool {
+	return r.compareKey() == b.compareKey()
+}
+
+// compareKey returns a copy of the record with request-only fields cleared, suitable for comparisons and map keys.
+func (r *Record) compareKey() Record {
+	k := *r
+	k.SetPtr = false
+	return k
+}
+
+// ErrSOAInvalid is returned (wrapped) when SOA record content cannot be parsed.
+var ErrSOAInvalid = errors.New("SOA record content is invalid") // nolint: golint
+
+// SOA holds the fields of an SOA record's content.
+type SOA struct {
+	Mname   string
+	Rname   string
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+}
+
+// ParseSOA parses the content of an SOA record, e.g. "ns1. hostmaster. 2024010101 10800 3600 604800 3600".
+func ParseSOA(content string) (SOA, error) {
+	fields := strings.Fields(content)
+	if len(fields) != 7 {
+		return SOA{}, errs.Wrap(ErrSOAInvalid, fmt.Errorf("expected 7 fields, got %d", len(fields)))
+	}
+
+	soa := SOA{Mname: fields[0], Rname: fields[1]}
+	for idx, value := range []*uint32{&soa.Serial, &soa.Refresh, &soa.Retry, &soa.Expire, &soa.Minimum} {
+		parsed, err := strconv.ParseUint(fields[idx+2], 10, 32)
+		if err != nil {
+			return SOA{}, errs.Wrap(ErrSOAInvalid, err)
+		}
+		*value = uint32(parsed)
+	}
+	return soa, nil
+}
+
+// String formats the SOA as record content.
+func (s SOA) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", s.Mname, s.Rname, s.Serial, s.Refresh, s.Retry, s.Expire, s.Minimum)
+}
+
 // Comment record which can be attached to RRsets
 type Comment struct {
 	Content    string    `json:"content"`
@@ -375,3 +793,152 @@ type Comment struct {
 func (c *Comment) Copy() Comment {
 	return *c
 }
+
+// commentJSON is the wire format of a Comment. PowerDNS sends modified_at as a Unix timestamp, and sets it to the
+// current time if it is omitted.
+type commentJSON struct {
+	Content    string `json:"content"`
+	Account    string `json:"account"`
+	ModifiedAt int64  `json:"modified_at,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. A zero ModifiedAt is omitted so the server fills in the current time.
+func (c Comment) MarshalJSON() ([]byte, error) {
+	raw := commentJSON{
+		Content: c.Content,
+		Account: c.Account,
+	}
+	if !c.ModifiedAt.IsZero() {
+		raw.ModifiedAt = c.ModifiedAt.Unix()
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *Comment) UnmarshalJSON(data []byte) error {
+	raw := commentJSON{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*c = Comment{
+		Content: raw.Content,
+		Account: raw.Account,
+	}
+	if raw.ModifiedAt != 0 {
+		c.ModifiedAt = time.Unix(raw.ModifiedAt, 0).UTC()
+	}
+	return nil
+}
+
+// StatisticType indicates the shape of a StatisticItem's value.
+type StatisticType string
+
+// nolint: golint
+const (
+	StatisticTypeItem StatisticType = "StatisticItem"
+	StatisticTypeMap  StatisticType = "MapStatisticItem"
+	StatisticTypeRing StatisticType = "RingStatisticItem"
+)
+
+// SimpleStatisticItem is a single named value within a map or ring statistic.
+type SimpleStatisticItem struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// StatisticItem implements a single statistic returned by the server. Which value fields are populated depends on
+// Type: a StatisticTypeItem has a single Value, while map and ring statistics have a list of Values (rings
+// additionally report their Size).
+type StatisticItem struct {
+	Name   string
+	Type   StatisticType
+	Value  string
+	Size   int
+	Values []SimpleStatisticItem
+}
+
+// statisticItemJSON is the wire format of a StatisticItem. The shape of value depends on type, so it is decoded
+// separately once the type is known.
+type statisticItemJSON struct {
+	Name  string          `json:"name"`
+	Type  StatisticType   `json:"type"`
+	Size  json.RawMessage `json:"size,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+// UnmarshalJSON decodes a StatisticItem, dispatching on its type to decode the value.
+func (s *StatisticItem) UnmarshalJSON(data []byte) error {
+	raw := statisticItemJSON{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	result := StatisticItem{
+		Name: raw.Name,
+		Type: raw.Type,
+	}
+
+	// Some recursor versions omit the type, so it is inferred from the value.
+	if result.Type == "" {
+		result.Type = StatisticTypeItem
+		if trimmed := bytes.TrimSpace(raw.Value); len(trimmed) > 0 && trimmed[0] == '[' {
+			result.Type = StatisticTypeMap
+		}
+	}
+
+	switch result.Type {
+	case StatisticTypeItem:
+		if err := json.Unmarshal(raw.Value, &result.Value); err != nil {
+			return err
+		}
+	case StatisticTypeMap, StatisticTypeRing:
+		if err := json.Unmarshal(raw.Value, &result.Values); err != nil {
+			return err
+		}
+		if len(raw.Size) > 0 {
+			// The server sends the ring size as a string, but accept a plain number too.
+			size, err := strconv.Atoi(strings.Trim(string(raw.Size), `"`))
+			if err != nil {
+				return err
+			}
+			result.Size = size
+		}
+	default:
+		return fmt.Errorf("unknown statistic type: %q", result.Type)
+	}
+
+	*s = result
+	return nil
+}
+
+// MarshalJSON encodes a StatisticItem in the same shape the server sends.
+func (s StatisticItem) MarshalJSON() ([]byte, error) {
+	raw := statisticItemJSON{
+		Name: s.Name,
+		Type: s.Type,
+	}
+
+	var err error
+	switch s.Type {
+	case StatisticTypeRing:
+		raw.Size, err = json.Marshal(strconv.Itoa(s.Size))
+		if err != nil {
+			return nil, err
+		}
+		fallthrough
+	case StatisticTypeMap:
+		values := s.Values
+		if values == nil {
+			values = []SimpleStatisticItem{}
+		}
+		raw.Value, err = json.Marshal(values)
+	default:
+		raw.Value, err = json.Marshal(s.Value)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(raw)
+}
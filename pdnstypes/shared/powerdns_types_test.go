@@ -1,12 +1,16 @@
 package shared_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/hashicorp/errwrap"
 	"github.com/satori/go.uuid"
 	. "github.com/wrouesnel/go.powerdns/pdnstypes/shared"
 	"github.com/wrouesnel/go.powerdns/testutil"
@@ -48,12 +52,36 @@ func (s *SharedTypeSuite) TestRecord(c *C) {
 	copiedRecord := record.Copy()
 	// Check the records are identical
 	c.Check(copiedRecord, DeepEquals, record)
+	c.Check(copiedRecord.Equals(record), Equals, true)
 	record.Content = "Different Content"
+	c.Check(copiedRecord.Equals(record), Equals, false)
 	c.Check(copiedRecord, Not(DeepEquals), record,
 		Commentf("Record still equal after modification:\n%s\n%s\n", spew.Sdump(copiedRecord),
 			spew.Sdump(record)))
 }
 
+func (s *SharedTypeSuite) TestRecordSetPtr(c *C) {
+	sent := Record{"192.0.2.1", false, true}
+
+	// Round-trip through JSON as the server would, which drops set-ptr
+	encoded, err := json.Marshal(sent)
+	c.Assert(err, IsNil)
+	returned := Record{}
+	c.Assert(json.Unmarshal(bytes.Replace(encoded, []byte(`"set-ptr":true`), []byte(`"set-ptr":false`), 1),
+		&returned), IsNil)
+	c.Assert(returned.SetPtr, Equals, false)
+
+	c.Assert(sent.Equals(returned), Equals, true)
+	c.Assert(Records{sent}.Equals(Records{returned}), Equals, true)
+	c.Assert(Records{sent}.IsSubsetOf(Records{returned}), Equals, true)
+	_, found := Records{sent}.ToMap()[returned]
+	c.Assert(found, Equals, true, Commentf("map keys should not include set-ptr"))
+
+	// Copies keep the directive so it is still sent to the server
+	c.Assert(sent.Copy().SetPtr, Equals, true)
+	c.Assert(Records{sent}.Union(Records{returned})[0].SetPtr, Equals, true)
+}
+
 func (s *SharedTypeSuite) TestRecords(c *C) {
 	records := testutil.MakeRecords()
 
@@ -98,6 +126,10 @@ func (s *SharedTypeSuite) TestRecords(c *C) {
 	c.Assert(records.IsSubsetOf(diffRecordCopy), Equals, true)
 	c.Assert(diffRecordCopy.IsSubsetOf(records), Equals, false)
 
+	// Equality holds in both directions, so a subset is not equal to its superset
+	c.Assert(records.Equals(diffRecordCopy), Equals, false)
+	c.Assert(diffRecordCopy.Equals(records), Equals, false)
+
 	// Test Union
 	c.Assert(records.Union(diffRecordCopy).Equals(diffRecordCopy), Equals, true)
 
@@ -210,10 +242,233 @@ func (s *SharedTypeSuite) TestRRSets(c *C) {
 	c.Assert(rrs.IsSubsetOf(diffRRSCopy), Equals, true)
 	c.Assert(diffRRSCopy.IsSubsetOf(rrs), Equals, false)
 
+	// Equality holds in both directions, so a subset is not equal to its superset
+	c.Assert(rrs.Equals(diffRRSCopy), Equals, false)
+	c.Assert(diffRRSCopy.Equals(rrs), Equals, false)
+
 	// Test Merge
 	c.Assert(rrs.Merge(diffRRSCopy).Equals(diffRRSCopy), Equals, true)
 }
 
+func (s *SharedTypeSuite) TestCopyLengths(c *C) {
+	// Copies must not be padded with zero-value elements
+	records := testutil.MakeRecords()
+	recordsCopy := records.Copy()
+	c.Assert(len(recordsCopy), Equals, len(records))
+	c.Assert(recordsCopy, DeepEquals, records)
+
+	rrs := testutil.MakeRRsets(".")
+	rrsCopy := rrs.Copy()
+	c.Assert(len(rrsCopy), Equals, len(rrs))
+	c.Assert(rrsCopy, DeepEquals, rrs)
+
+	// Comments are copied, not shared
+	rr := RRset{Name: "a.test.zone.", Type: "A", Comments: []Comment{{Content: "owner: ops"}}}
+	rrCopy := rr.Copy()
+	c.Assert(rrCopy.Comments, DeepEquals, rr.Comments)
+	rrCopy.Comments[0].Content = "changed"
+	c.Assert(rr.Comments[0].Content, Equals, "owner: ops")
+
+	// Merging with ourselves should produce exactly one RRset per unique name
+	merged := rrs.Merge(rrsCopy)
+	c.Assert(len(merged), Equals, len(rrs.ToMap()))
+	c.Assert(merged.Equals(rrs), Equals, true)
+}
+
+func (s *SharedTypeSuite) TestRRSetsDifferenceRecordLevel(c *C) {
+	commonRecords := Records{
+		Record{"192.0.2.1", false, false},
+		Record{"192.0.2.2", false, false},
+	}
+	ours := RRsets{
+		RRset{
+			Name:    "overlap.test.zone.",
+			Type:    "A",
+			TTL:     300,
+			Records: append(commonRecords.Copy(), Record{"192.0.2.3", false, false}),
+		},
+	}
+	theirs := RRsets{
+		RRset{
+			Name:    "overlap.test.zone.",
+			Type:    "A",
+			TTL:     300,
+			Records: append(commonRecords.Copy(), Record{"192.0.2.4", false, false}),
+		},
+	}
+
+	// Only the record unique to our side should be returned
+	diff := ours.Difference(theirs)
+	c.Assert(len(diff), Equals, 1)
+	c.Assert(diff[0].UniqueName(), Equals, ours[0].UniqueName())
+	c.Assert(diff[0].Records, DeepEquals, Records{Record{"192.0.2.3", false, false}})
+
+	// And the reverse
+	diff = theirs.Difference(ours)
+	c.Assert(len(diff), Equals, 1)
+	c.Assert(diff[0].Records, DeepEquals, Records{Record{"192.0.2.4", false, false}})
+
+	// Identical RRsets have no difference
+	c.Assert(len(ours.Difference(ours.Copy())), Equals, 0)
+}
+
+func (s *SharedTypeSuite) TestRRSetsIntersection(c *C) {
+	ours := RRsets{
+		RRset{Name: "a.test.zone.", Type: "A", TTL: 300,
+			Records: Records{{"192.0.2.1", false, false}, {"192.0.2.2", false, false}}},
+		RRset{Name: "b.test.zone.", Type: "A", TTL: 300,
+			Records: Records{{"192.0.2.3", false, false}}},
+	}
+	theirs := RRsets{
+		RRset{Name: "a.test.zone.", Type: "A", TTL: 300,
+			Records: Records{{"192.0.2.2", false, false}, {"192.0.2.4", false, false}}},
+		// Same records but a different TTL does not intersect
+		RRset{Name: "b.test.zone.", Type: "A", TTL: 3600,
+			Records: Records{{"192.0.2.3", false, false}}},
+	}
+
+	intersection := ours.Intersection(theirs)
+	c.Assert(len(intersection), Equals, 1)
+	c.Assert(intersection[0].UniqueName(), Equals, RRsetUniqueName{"a.test.zone.", "A"})
+	c.Assert(intersection[0].TTL, Equals, uint32(300))
+	c.Assert(intersection[0].Records, DeepEquals, Records{{"192.0.2.2", false, false}})
+}
+
+func (s *SharedTypeSuite) TestSort(c *C) {
+	records := Records{{"b", false, false}, {"a", true, false}, {"c", false, false}, {"a", false, false}}
+	records.Sort()
+	c.Assert(records, DeepEquals, Records{{"a", false, false}, {"a", true, false}, {"b", false, false},
+		{"c", false, false}})
+
+	rrsets := RRsets{
+		{Name: "b.test.zone.", Type: "A"},
+		{Name: "a.test.zone.", Type: "TXT"},
+		{Name: "a.test.zone.", Type: "AAAA"},
+	}
+	rrsets.Sort()
+	c.Assert(rrsets, DeepEquals, RRsets{
+		{Name: "a.test.zone.", Type: "AAAA"},
+		{Name: "a.test.zone.", Type: "TXT"},
+		{Name: "b.test.zone.", Type: "A"},
+	})
+}
+
+func (s *SharedTypeSuite) TestRRsetsSetOperationsAreOrdered(c *C) {
+	ours := RRsets{}
+	theirs := RRsets{}
+	for i := 20; i > 0; i-- {
+		ours = append(ours, RRset{Name: fmt.Sprintf("host%d.test.zone.", i), Type: "A", TTL: 300,
+			Records: Records{{"192.0.2.1", false, false}}})
+		theirs = append(theirs, RRset{Name: fmt.Sprintf("other%d.test.zone.", i), Type: "A", TTL: 300,
+			Records: Records{{"192.0.2.1", false, false}}})
+	}
+
+	// The results follow the order of the inputs rather than map iteration order.
+	c.Assert(ours.Difference(theirs), DeepEquals, ours)
+	c.Assert(ours.Intersection(ours.Copy()), DeepEquals, ours)
+	c.Assert(ours.Merge(theirs), DeepEquals, append(ours.Copy(), theirs...))
+}
+
+func (s *SharedTypeSuite) TestRRsetsFilter(c *C) {
+	rrsets := RRsets{
+		{Name: "test.zone.", Type: "MX", Records: Records{{"10 mail.test.zone.", false, false}}},
+		{Name: "_acme-challenge.test.zone.", Type: "TXT", Records: Records{{`"token"`, false, false}}},
+		{Name: "www._acme-challenge.Test.Zone.", Type: "TXT", Records: Records{{`"token"`, false, false}}},
+		{Name: "x_acme-challenge.test.zone.", Type: "A", Records: Records{{"192.0.2.1", false, false}}},
+	}
+
+	c.Assert(rrsets.FilterByType("mx"), DeepEquals, RRsets{rrsets[0]})
+	c.Assert(rrsets.FilterByType("TXT", "A"), DeepEquals, RRsets{rrsets[1], rrsets[2], rrsets[3]})
+	c.Assert(rrsets.FilterByType(), HasLen, 0)
+
+	c.Assert(rrsets.FilterByName("_acme-challenge.test.zone"), DeepEquals, RRsets{rrsets[1], rrsets[2]})
+	c.Assert(rrsets.FilterByName("test.zone."), DeepEquals, rrsets)
+	c.Assert(rrsets.FilterByName("other.zone."), HasLen, 0)
+
+	// The results are copies
+	filtered := rrsets.FilterByType("MX")
+	filtered[0].Records[0].Content = "20 mail.test.zone."
+	c.Assert(rrsets[0].Records[0].Content, Equals, "10 mail.test.zone.")
+}
+
+func (s *SharedTypeSuite) TestDedup(c *C) {
+	rrsets := RRsets{
+		{Name: "a.test.zone.", Type: "A", Records: Records{
+			{"192.0.2.1", false, false}, {"192.0.2.2", false, false}, {"192.0.2.1", false, true},
+			{"192.0.2.1", true, false},
+		}},
+		{Name: "b.test.zone.", Type: "A"},
+		{Name: "c.test.zone.", Type: "A", Records: Records{
+			{"192.0.2.3", true, false}, {"192.0.2.4", true, false}, {"192.0.2.3", false, false},
+			{"192.0.2.4", true, false},
+		}},
+	}
+	rrsets.Dedup()
+
+	c.Assert(rrsets[0].Records, DeepEquals, Records{{"192.0.2.1", false, false}, {"192.0.2.2", false, false}})
+	c.Assert(rrsets[1].Records, IsNil)
+	// A disabled and an enabled copy of the same content leave a single enabled record.
+	c.Assert(rrsets[2].Records, DeepEquals, Records{{"192.0.2.3", false, false}, {"192.0.2.4", true, false}})
+}
+
+func (s *SharedTypeSuite) TestRRsetsIgnoringTTL(c *C) {
+	desired := RRsets{
+		{Name: "a.test.zone.", Type: "A", TTL: 300, Records: Records{{"192.0.2.1", false, false}}},
+		{Name: "b.test.zone.", Type: "A", TTL: 300, Records: Records{{"192.0.2.2", false, false}}},
+	}
+	actual := desired.Copy()
+	actual[0].TTL = 298
+
+	c.Assert(desired.Equals(actual), Equals, false)
+	c.Assert(desired.EqualsIgnoringTTL(actual), Equals, true)
+	c.Assert(desired.Difference(actual), HasLen, 0)
+
+	// Record and membership differences still count
+	actual[1].Records = Records{{"192.0.2.3", false, false}}
+	c.Assert(desired.EqualsIgnoringTTL(actual), Equals, false)
+	c.Assert(desired.Difference(actual), DeepEquals, RRsets{
+		{Name: "b.test.zone.", Type: "A", TTL: 300, Records: Records{{"192.0.2.2", false, false}}},
+	})
+	c.Assert(desired.EqualsIgnoringTTL(desired[:1]), Equals, false)
+	c.Assert(desired[:1].EqualsIgnoringTTL(desired), Equals, false)
+}
+
+func (s *SharedTypeSuite) TestRRsetsEqualsWithOptions(c *C) {
+	desired := RRsets{
+		{Name: "test.zone.", Type: "MX", TTL: 300, Records: Records{
+			{"10 Mail.Test.Zone.", false, false}, {"20 backup.test.zone.", false, false},
+		}},
+		{Name: "www.test.zone.", Type: "CNAME", TTL: 300, Records: Records{{"test.zone.", false, false}}},
+	}
+	c.Assert(desired.EqualsWithOptions(desired.Copy(), CompareOptions{}), Equals, true)
+
+	// As returned by the server: reordered, lowercased, with drifted TTLs and a disabled record
+	actual := RRsets{
+		{Name: "www.test.zone.", Type: "CNAME", TTL: 298, Records: Records{{"test.zone.", true, false}}},
+		{Name: "test.zone.", Type: "MX", TTL: 300, Records: Records{
+			{"20 backup.test.zone.", false, false}, {"10 mail.test.zone.", false, false},
+		}},
+	}
+	all := CompareOptions{IgnoreTTL: true, IgnoreOrder: true, CaseInsensitiveContent: true, IgnoreDisabled: true}
+	c.Assert(desired.EqualsWithOptions(actual, all), Equals, true)
+	c.Assert(desired.Equals(actual), Equals, false)
+
+	// Each option is needed
+	for _, opts := range []CompareOptions{
+		{IgnoreOrder: true, CaseInsensitiveContent: true, IgnoreDisabled: true},
+		{IgnoreTTL: true, CaseInsensitiveContent: true, IgnoreDisabled: true},
+		{IgnoreTTL: true, IgnoreOrder: true, IgnoreDisabled: true},
+		{IgnoreTTL: true, IgnoreOrder: true, CaseInsensitiveContent: true},
+	} {
+		c.Check(desired.EqualsWithOptions(actual, opts), Equals, false, Commentf("%+v", opts))
+	}
+
+	// Genuine differences are never ignored
+	actual[1].Records = actual[1].Records[:1]
+	c.Assert(desired.EqualsWithOptions(actual, all), Equals, false)
+	c.Assert(desired.EqualsWithOptions(desired[:1], all), Equals, false)
+}
+
 func (s *SharedTypeSuite) TestZone(c *C) {
 	z := testutil.MakeZone()
 
@@ -230,3 +485,204 @@ func (s *SharedTypeSuite) TestZone(c *C) {
 	c.Assert(z.HeaderEquals(b), Equals, true)
 	c.Assert(z.Equals(b), Equals, false)
 }
+
+func (s *SharedTypeSuite) TestZoneNormalize(c *C) {
+	z := Zone{Name: "Example.COM"}
+	z.Normalize()
+	c.Assert(z.Name, Equals, "example.com.")
+
+	// Already normalized names are unchanged
+	z.Normalize()
+	c.Assert(z.Name, Equals, "example.com.")
+}
+
+func (s *SharedTypeSuite) TestZoneValidate(c *C) {
+	label := strings.Repeat("a", 63)
+
+	c.Assert((&Zone{Name: "example.com."}).Validate(), IsNil)
+	c.Assert((&Zone{Name: "example.com"}).Validate(), IsNil)
+	c.Assert((&Zone{Name: "."}).Validate(), IsNil)
+	c.Assert((&Zone{Name: label + ".com."}).Validate(), IsNil)
+
+	c.Assert((&Zone{Name: ""}).Validate(), Equals, ErrZoneNameEmpty)
+	c.Assert((&Zone{Name: "example..com."}).Validate(), Equals, ErrZoneNameEmptyLabel)
+	c.Assert((&Zone{Name: label + "a.com."}).Validate(), Equals, ErrZoneNameLabelTooLong)
+
+	// 4 labels of 63 octets plus length octets and the root is 257 octets.
+	long := strings.Join([]string{label, label, label, label}, ".") + "."
+	c.Assert((&Zone{Name: long}).Validate(), Equals, ErrZoneNameTooLong)
+	// 3 labels of 63 and one of 61 is exactly 255 octets.
+	c.Assert((&Zone{Name: strings.Join([]string{label, label, label, label[:61]}, ".")}).Validate(), IsNil)
+}
+
+func (s *SharedTypeSuite) TestCommentJSON(c *C) {
+	// As returned by PowerDNS in an RRset
+	payload := `{"content": "Ticket OPS-42", "account": "ops", "modified_at": 1577836800}`
+
+	comment := Comment{}
+	c.Assert(json.Unmarshal([]byte(payload), &comment), IsNil)
+	c.Assert(comment.Content, Equals, "Ticket OPS-42")
+	c.Assert(comment.Account, Equals, "ops")
+	c.Assert(comment.ModifiedAt.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)), Equals, true)
+
+	out, err := json.Marshal(comment)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, `{"content":"Ticket OPS-42","account":"ops","modified_at":1577836800}`)
+
+	// A zero time is omitted so the server sets it
+	out, err = json.Marshal(Comment{Content: "new"})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, `{"content":"new","account":""}`)
+
+	roundTrip := Comment{}
+	c.Assert(json.Unmarshal(out, &roundTrip), IsNil)
+	c.Assert(roundTrip.ModifiedAt.IsZero(), Equals, true)
+}
+
+func (s *SharedTypeSuite) TestErrorJSON(c *C) {
+	// As returned by PowerDNS for a 422
+	payload := `{"error": "RRsets are invalid", "errors": ["www.test.zone. is out of zone", "TTL is too low"]}`
+
+	pdnsErr := Error{}
+	c.Assert(json.Unmarshal([]byte(payload), &pdnsErr), IsNil)
+	c.Assert(pdnsErr, DeepEquals, Error{
+		Message: "RRsets are invalid",
+		Errors:  []Error{{Message: "www.test.zone. is out of zone"}, {Message: "TTL is too low"}},
+	})
+	c.Assert(pdnsErr.WrappedErrors(), HasLen, 2)
+
+	out, err := json.Marshal(pdnsErr)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals,
+		`{"error":"RRsets are invalid","errors":["www.test.zone. is out of zone","TTL is too low"]}`)
+
+	// Nested objects are accepted too
+	nested := Error{}
+	c.Assert(json.Unmarshal([]byte(`{"error": "outer", "errors": [{"error": "inner"}]}`), &nested), IsNil)
+	c.Assert(nested, DeepEquals, Error{Message: "outer", Errors: []Error{{Message: "inner"}}})
+
+	plain := Error{}
+	c.Assert(json.Unmarshal([]byte(`{"error": "Not Found"}`), &plain), IsNil)
+	c.Assert(plain, DeepEquals, Error{Message: "Not Found"})
+	out, err = json.Marshal(plain)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, `{"error":"Not Found"}`)
+
+	c.Assert(json.Unmarshal([]byte(`{"error": "bad", "errors": [1]}`), &Error{}), NotNil)
+}
+
+func (s *SharedTypeSuite) TestSOA(c *C) {
+	content := "ns1.example.com. hostmaster.example.com. 2024010101 10800 3600 604800 3600"
+
+	soa, err := ParseSOA(content)
+	c.Assert(err, IsNil)
+	c.Assert(soa, DeepEquals, SOA{
+		Mname:   "ns1.example.com.",
+		Rname:   "hostmaster.example.com.",
+		Serial:  2024010101,
+		Refresh: 10800,
+		Retry:   3600,
+		Expire:  604800,
+		Minimum: 3600,
+	})
+	c.Assert(soa.String(), Equals, content)
+
+	// Extra whitespace is tolerated
+	soa, err = ParseSOA("  ns1.example.com.\thostmaster.example.com.  1 2 3 4 5 ")
+	c.Assert(err, IsNil)
+	c.Assert(soa.String(), Equals, "ns1.example.com. hostmaster.example.com. 1 2 3 4 5")
+
+	// Serials use the full unsigned 32-bit range
+	soa, err = ParseSOA("ns1. hostmaster. 4294967295 1 2 3 4")
+	c.Assert(err, IsNil)
+	c.Assert(soa.Serial, Equals, uint32(4294967295))
+
+	for _, invalid := range []string{
+		"",
+		"ns1. hostmaster. 1 2 3 4",
+		"ns1. hostmaster. 1 2 3 4 5 6",
+		"ns1. hostmaster. one 2 3 4 5",
+		"ns1. hostmaster. 4294967296 2 3 4 5",
+		"ns1. hostmaster. -1 2 3 4 5",
+	} {
+		_, err := ParseSOA(invalid)
+		c.Check(errwrap.Contains(err, ErrSOAInvalid.Error()), Equals, true, Commentf("%q", invalid))
+	}
+}
+
+func (s *SharedTypeSuite) TestGroupRecords(c *C) {
+	rrsets := GroupRecords([]NamedRecord{
+		{Name: "www.test.zone.", Type: "A", TTL: 300, Content: "192.0.2.1"},
+		{Name: "mail.test.zone.", Type: "MX", TTL: 300, Content: "10 mx.test.zone."},
+		{Name: "www.test.zone.", Type: "A", TTL: 60, Content: "192.0.2.2", Disabled: true},
+		{Name: "www.test.zone.", Type: "AAAA", TTL: 300, Content: "2001:db8::1"},
+	})
+
+	c.Assert(rrsets, DeepEquals, RRsets{
+		{Name: "www.test.zone.", Type: "A", TTL: 60, Records: Records{
+			{Content: "192.0.2.1"}, {Content: "192.0.2.2", Disabled: true},
+		}},
+		{Name: "mail.test.zone.", Type: "MX", TTL: 300, Records: Records{{Content: "10 mx.test.zone."}}},
+		{Name: "www.test.zone.", Type: "AAAA", TTL: 300, Records: Records{{Content: "2001:db8::1"}}},
+	})
+
+	c.Assert(len(GroupRecords(nil)), Equals, 0)
+}
+
+func (s *SharedTypeSuite) TestServerInfoSemVer(c *C) {
+	for version, expected := range map[string][3]int{
+		"4.7.3":                   {4, 7, 3},
+		"4.8.0-alpha1":            {4, 8, 0},
+		"4.9.0-beta2.12.master.g": {4, 9, 0},
+		"4.1":                     {4, 1, 0},
+		"4.2.1.1":                 {4, 2, 1},
+		"4.3.0+build5":            {4, 3, 0},
+	} {
+		major, minor, patch, err := ServerInfo{Version: version}.SemVer()
+		c.Check(err, IsNil, Commentf("%s", version))
+		c.Check([3]int{major, minor, patch}, Equals, expected, Commentf("%s", version))
+	}
+
+	for _, version := range []string{"", "4", "four.seven.three", "4.x.1", "4.-1.0"} {
+		_, _, _, err := ServerInfo{Version: version}.SemVer()
+		c.Check(errwrap.Contains(err, ErrServerVersionInvalid.Error()), Equals, true, Commentf("%s", version))
+	}
+}
+
+func (s *SharedTypeSuite) TestStatisticItem(c *C) {
+	payload := `[
+	{"name": "corrupt-packets", "type": "StatisticItem", "value": "0"},
+	{"name": "response-by-qtype", "type": "MapStatisticItem", "value": [{"name": "A", "value": "12"}]},
+	{"name": "logmessages", "type": "RingStatisticItem", "size": "10000", "value": [{"name": "started", "value": "1"}]}
+]`
+
+	stats := []StatisticItem{}
+	c.Assert(json.Unmarshal([]byte(payload), &stats), IsNil)
+	c.Assert(stats, DeepEquals, []StatisticItem{
+		{Name: "corrupt-packets", Type: StatisticTypeItem, Value: "0"},
+		{Name: "response-by-qtype", Type: StatisticTypeMap, Values: []SimpleStatisticItem{{"A", "12"}}},
+		{Name: "logmessages", Type: StatisticTypeRing, Size: 10000, Values: []SimpleStatisticItem{{"started", "1"}}},
+	})
+
+	// Round-trip through our own encoding
+	encoded, err := json.Marshal(stats)
+	c.Assert(err, IsNil)
+	roundTripped := []StatisticItem{}
+	c.Assert(json.Unmarshal(encoded, &roundTripped), IsNil)
+	c.Assert(roundTripped, DeepEquals, stats)
+
+	// Unknown types are rejected rather than silently dropped
+	unknown := StatisticItem{}
+	c.Assert(json.Unmarshal([]byte(`{"name": "x", "type": "Bogus", "value": 1}`), &unknown), NotNil)
+
+	// Recursors which omit the type are decoded from the shape of the value
+	untyped := []StatisticItem{}
+	c.Assert(json.Unmarshal([]byte(`[
+	{"name": "cache-entries", "value": "512"},
+	{"name": "response-by-qtype", "value": [{"name": "AAAA", "value": "3"}]}
+]`), &untyped), IsNil)
+	c.Assert(untyped, DeepEquals, []StatisticItem{
+		{Name: "cache-entries", Type: StatisticTypeItem, Value: "512"},
+		{Name: "response-by-qtype", Type: StatisticTypeMap, Values: []SimpleStatisticItem{{"AAAA", "3"}}},
+	})
+}
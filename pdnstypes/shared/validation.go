@@ -0,0 +1,188 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/wrouesnel/go.powerdns/internal/errs"
+)
+
+// ErrRRsetInvalid is returned (wrapped) by Validate when an RRset would be rejected by the server. The wrapped error
+// names the RRset and the problem.
+var ErrRRsetInvalid = errors.New("RRset is invalid") // nolint: golint
+
+// rrTypes lists the record types PowerDNS accepts, in the order RRTypes returns them.
+var rrTypes = []string{
+	"A",
+	"AAAA",
+	"AFSDB",
+	"ALIAS",
+	"APL",
+	"CAA",
+	"CDNSKEY",
+	"CDS",
+	"CERT",
+	"CNAME",
+	"CSYNC",
+	"DHCID",
+	"DLV",
+	"DNAME",
+	"DNSKEY",
+	"DS",
+	"HIP",
+	"HTTPS",
+	"IPSECKEY",
+	"KEY",
+	"KX",
+	"LOC",
+	"LUA",
+	"MX",
+	"NAPTR",
+	"NS",
+	"NSEC",
+	"NSEC3",
+	"NSEC3PARAM",
+	"OPENPGPKEY",
+	"PTR",
+	"RRSIG",
+	"RP",
+	"SIG",
+	"SMIMEA",
+	"SOA",
+	"SPF",
+	"SRV",
+	"SSHFP",
+	"SVCB",
+	"TA",
+	"TKEY",
+	"TLSA",
+	"TSIG",
+	"TXT",
+	"URI",
+	"ZONEMD",
+}
+
+// rrTypeSet indexes rrTypes for IsValidRRType.
+var rrTypeSet = func() map[string]bool {
+	r := make(map[string]bool, len(rrTypes))
+	for _, rrtype := range rrTypes {
+		r[rrtype] = true
+	}
+	return r
+}()
+
+// RRTypes returns a copy of the list of record types IsValidRRType accepts by name.
+func RRTypes() []string {
+	return append([]string{}, rrTypes...)
+}
+
+// IsValidRRType returns whether rrtype names a record type PowerDNS accepts, ignoring case. The RFC3597 generic
+// form (e.g. "TYPE65534") is also accepted.
+func IsValidRRType(rrtype string) bool {
+	rrtype = strings.ToUpper(rrtype)
+	if rrTypeSet[rrtype] {
+		return true
+	}
+
+	if number := strings.TrimPrefix(rrtype, "TYPE"); number != rrtype && number != "" {
+		code, err := strconv.ParseUint(number, 10, 16)
+		return err == nil && code > 0
+	}
+	return false
+}
+
+// dnssecTypes may coexist with a CNAME at the same name.
+var dnssecTypes = map[string]bool{
+	"RRSIG": true,
+	"NSEC":  true,
+	"NSEC3": true,
+}
+
+// Validate checks the RRset is well-formed: it has a fully qualified name and a known type, and its records have
+// content appropriate to the type. Only A, AAAA and CNAME contents are checked in detail. An RRset without records,
+// as used to delete an RRset, is valid.
+func (rr *RRset) Validate() error {
+	if err := rr.validate(); err != nil {
+		return errs.Wrap(ErrRRsetInvalid, fmt.Errorf("%s %s: %v", rr.Name, rr.Type, err))
+	}
+	return nil
+}
+
+func (rr *RRset) validate() error {
+	if rr.Name == "" {
+		return errors.New("name is empty")
+	}
+	if !strings.HasSuffix(rr.Name, ".") {
+		return errors.New("name is not fully qualified")
+	}
+	if rr.Type == "" {
+		return errors.New("type is empty")
+	}
+	if !IsValidRRType(rr.Type) {
+		return errors.New("type is not a known record type")
+	}
+
+	rrtype := strings.ToUpper(rr.Type)
+	if rrtype == "CNAME" && len(rr.Records) > 1 {
+		return errors.New("a CNAME RRset may only have one record")
+	}
+
+	for _, record := range rr.Records {
+		if record.Content == "" {
+			return errors.New("record content is empty")
+		}
+
+		switch rrtype {
+		case "A":
+			if ip := net.ParseIP(record.Content); ip == nil || ip.To4() == nil || strings.Contains(record.Content, ":") {
+				return fmt.Errorf("%q is not an IPv4 address", record.Content)
+			}
+		case "AAAA":
+			if ip := net.ParseIP(record.Content); ip == nil || !strings.Contains(record.Content, ":") {
+				return fmt.Errorf("%q is not an IPv6 address", record.Content)
+			}
+		case "CNAME":
+			if !strings.HasSuffix(record.Content, ".") {
+				return fmt.Errorf("%q is not a fully qualified name", record.Content)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks each RRset with RRset.Validate, and that no CNAME RRset shares its name with RRsets of other
+// types (other than DNSSEC types). The first problem found is returned.
+func (rrs RRsets) Validate() error {
+	cnames := map[string]bool{}
+	others := map[string]string{}
+
+	for idx := range rrs {
+		rr := &rrs[idx]
+		if err := rr.Validate(); err != nil {
+			return err
+		}
+
+		// RRsets without records are deletions, so cannot conflict.
+		if len(rr.Records) == 0 {
+			continue
+		}
+
+		name := strings.ToLower(rr.Name)
+		rrtype := strings.ToUpper(rr.Type)
+		switch {
+		case rrtype == "CNAME":
+			cnames[name] = true
+		case !dnssecTypes[rrtype]:
+			others[name] = rrtype
+		}
+
+		if cnames[name] && others[name] != "" {
+			return errs.Wrap(ErrRRsetInvalid, fmt.Errorf("%s: CNAME cannot coexist with %s", rr.Name,
+				others[name]))
+		}
+	}
+	return nil
+}
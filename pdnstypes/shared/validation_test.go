@@ -0,0 +1,59 @@
+package shared_test
+
+import (
+	"github.com/hashicorp/errwrap"
+	. "github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+	. "gopkg.in/check.v1"
+)
+
+func (s *SharedTypeSuite) TestRRsetValidate(c *C) {
+	valid := RRsets{
+		{Name: "a.test.zone.", Type: "A", Records: Records{{Content: "192.0.2.1"}}},
+		{Name: "a.test.zone.", Type: "AAAA", Records: Records{{Content: "2001:db8::1"}}},
+		{Name: "www.test.zone.", Type: "CNAME", Records: Records{{Content: "a.test.zone."}}},
+		{Name: "www.test.zone.", Type: "RRSIG", Records: Records{{Content: "CNAME 13 3 300 ..."}}},
+		{Name: "test.zone.", Type: "MX", Records: Records{{Content: "10 mail.test.zone."}}},
+		// A deletion has no records
+		{Name: "gone.test.zone.", Type: "A"},
+	}
+	c.Assert(valid.Validate(), IsNil)
+
+	for _, rrset := range []RRset{
+		{Name: "", Type: "A"},
+		{Name: "a.test.zone", Type: "A"},
+		{Name: "a.test.zone.", Type: ""},
+		{Name: "www.test.zone.", Type: "CNMAE", Records: Records{{Content: "a.test.zone."}}},
+		{Name: "a.test.zone.", Type: "A", Records: Records{{Content: ""}}},
+		{Name: "a.test.zone.", Type: "A", Records: Records{{Content: "2001:db8::1"}}},
+		{Name: "a.test.zone.", Type: "A", Records: Records{{Content: "::ffff:192.0.2.1"}}},
+		{Name: "a.test.zone.", Type: "A", Records: Records{{Content: "192.0.2.256"}}},
+		{Name: "a.test.zone.", Type: "AAAA", Records: Records{{Content: "192.0.2.1"}}},
+		{Name: "www.test.zone.", Type: "CNAME", Records: Records{{Content: "a.test.zone"}}},
+		{Name: "www.test.zone.", Type: "CNAME", Records: Records{{Content: "a.test.zone."}, {Content: "b.test.zone."}}},
+	} {
+		err := rrset.Validate()
+		c.Check(errwrap.Contains(err, ErrRRsetInvalid.Error()), Equals, true, Commentf("%#v", rrset))
+	}
+
+	conflicting := RRsets{
+		{Name: "www.test.zone.", Type: "CNAME", Records: Records{{Content: "a.test.zone."}}},
+		{Name: "WWW.test.zone.", Type: "TXT", Records: Records{{Content: `"text"`}}},
+	}
+	err := conflicting.Validate()
+	c.Assert(errwrap.Contains(err, ErrRRsetInvalid.Error()), Equals, true)
+	c.Assert(errwrap.Contains(err, "WWW.test.zone.: CNAME cannot coexist with TXT"), Equals, true)
+}
+
+func (s *SharedTypeSuite) TestIsValidRRType(c *C) {
+	for _, rrtype := range []string{"A", "aaaa", "CNAME", "TXT", "NSEC3PARAM", "TYPE65534"} {
+		c.Check(IsValidRRType(rrtype), Equals, true, Commentf("%s", rrtype))
+	}
+	for _, rrtype := range []string{"", "CNMAE", "TYPE", "TYPE0", "TYPE65536", "TYPEA"} {
+		c.Check(IsValidRRType(rrtype), Equals, false, Commentf("%s", rrtype))
+	}
+
+	// RRTypes returns a copy
+	rrtypes := RRTypes()
+	rrtypes[0] = "CNMAE"
+	c.Assert(RRTypes()[0], Equals, "A")
+}
@@ -1,44 +1,159 @@
 package powerdns
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
+	"runtime/debug"
+	"strings"
 
 	"fmt"
 	"net"
 	"time"
 
 	"github.com/hashicorp/errwrap"
+	"github.com/wrouesnel/go.powerdns/internal/errs"
 	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+	"golang.org/x/time/rate"
 )
 
 // nolint: golint
 var (
 	// ErrClientNilError
-	ErrClientNilError            = errors.New("No URL supplied for API client.")
-	ErrClientSubPathError        = errors.New("Subpath URI was badly formed.")
-	ErrClientRequestParsingError = errors.New("Error parsing request parameters locally")
-	ErrClientRequestIsAbs        = errors.New("Absolute URI is not allowed")
-	ErrClientRequestFailed       = errors.New("Error sending request to server")
-	ErrClientServerUnknownStatus = errors.New("Server returned a StatusCode it shouldn't have.")
-	ErrClientServerResponse      = errors.New("Server returned an error response")
+	ErrClientNilError              = errors.New("No URL supplied for API client.")
+	ErrClientSubPathError          = errors.New("Subpath URI was badly formed.")
+	ErrClientRequestParsingError   = errors.New("Error parsing request parameters locally")
+	ErrClientRequestIsAbs          = errors.New("Absolute URI is not allowed")
+	ErrClientRequestFailed         = errors.New("Error sending request to server")
+	ErrClientServerUnknownStatus   = errors.New("Server returned a StatusCode it shouldn't have.")
+	ErrClientServerResponse        = errors.New("Server returned an error response")
+	ErrClientZoneNameInvalid       = errors.New("Zone name is not a valid domain name")
+	ErrNotFound                    = errors.New("Requested resource was not found on the server")
+	ErrUnauthorized                = errors.New("Server rejected the credentials. Is the API key correct?")
+	ErrClientZoneNotDNSSEC         = errors.New("Zone does not have DNSSEC enabled")
+	ErrClientSearchTypeInvalid     = errors.New("Search object type must be one of all, zone, record or comment")
+	ErrClientZoneNotMaster         = errors.New("Zone is not a master or native zone")
+	ErrClientRateLimitWait         = errors.New("Request was cancelled while waiting for the rate limiter")
+	ErrClientZoneKindInvalid       = errors.New("Zone kind must be one of Native, Master or Slave")
+	ErrClientRecordContentEmpty    = errors.New("At least one record content is required")
+	ErrClientInvalidEndpoint       = errors.New("Endpoint must be an http or https URL, e.g. http://localhost:8081/")
+	ErrClientResponseTooLarge      = errors.New("Server response exceeded the maximum response size")
+	ErrClientNameserversWithNS     = errors.New("Nameservers must not be given as well as an NS RRset at the zone apex")
+	ErrClientSerialWait            = errors.New("Zone did not reach the expected serial before the wait ended")
+	ErrClientForwardServersEmpty   = errors.New("At least one server is required for a forwarded zone")
+	ErrCircuitOpen                 = errors.New("Circuit breaker is open after repeated failures, request not sent")
+	ErrClientPTRAddressInvalid     = errors.New("Address must be an IPv4 address for A records or IPv6 for AAAA records")
+	ErrClientReverseZoneMissing    = errors.New("No reverse zone exists for the address to hold its PTR record")
+	ErrClientCryptokeyNoPrivateKey = errors.New("Cryptokey has no private key, so cannot be imported")
 )
 
+// IsNotFound returns true if err is, or wraps, ErrNotFound. This is the case whenever the server responds that the
+// requested zone, cryptokey, metadata or other resource does not exist.
+func IsNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	return err == ErrNotFound || errwrap.Contains(err, ErrNotFound.Error())
+}
+
+// IsUnauthorized returns true if err is, or wraps, ErrUnauthorized. This is the case whenever the server responds
+// with 401 Unauthorized or 403 Forbidden, usually because the API key is wrong or missing.
+func IsUnauthorized(err error) bool {
+	if err == nil {
+		return false
+	}
+	return err == ErrUnauthorized || errwrap.Contains(err, ErrUnauthorized.Error())
+}
+
+// ServerError is returned (wrapped) when the server responds with an unsuccessful status code. Err holds the
+// error decoded from the response, which is normally a shared.Error. The undecoded response is kept too, since
+// PowerDNS's error responses are not always consistent.
+type ServerError struct {
+	statusCode int
+	body       []byte
+	Err        error
+}
+
+func (err ServerError) Error() string {
+	if err.Err == nil {
+		return fmt.Sprintf("Server returned status %d", err.statusCode)
+	}
+	return fmt.Sprintf("Server returned status %d: %v", err.statusCode, err.Err)
+}
+
+// StatusCode returns the HTTP status code the server responded with.
+func (err ServerError) StatusCode() int {
+	return err.statusCode
+}
+
+// RawBody returns a copy of the response body sent by the server.
+func (err ServerError) RawBody() []byte {
+	r := make([]byte, len(err.body))
+	copy(r, err.body)
+	return r
+}
+
+// WrappedErrors implements errwrap.Wrapper
+func (err ServerError) WrappedErrors() []error {
+	if err.Err == nil {
+		return []error{}
+	}
+	return []error{err.Err}
+}
+
+// Unwrap returns Err, for errors.Is and errors.As.
+func (err ServerError) Unwrap() error {
+	return err.Err
+}
+
+// StatusCode returns the HTTP status code of the server response which caused err, if there was one.
+func StatusCode(err error) (int, bool) {
+	if serverErr, ok := errwrap.GetType(err, ServerError{}).(ServerError); ok {
+		return serverErr.StatusCode(), true
+	}
+	return 0, false
+}
+
+// ServerErrorFrom returns the error the server reported in the response which caused err, including any nested
+// errors it gave (e.g. for each invalid RRset). It returns false if err was not caused by a server response, or if
+// the response did not hold a PowerDNS error.
+func ServerErrorFrom(err error) (shared.Error, bool) {
+	// The nested errors are shared.Errors too, so take the outermost rather than errwrap.GetType's innermost.
+	if found := errwrap.GetAllType(err, shared.Error{}); len(found) > 0 {
+		return found[0].(shared.Error), true
+	}
+	return shared.Error{}, false
+}
+
 // ErrClientServerResponseUnreadable is returned when the server sends us something non-sensical, and includes
 // the body of the response.
 type ErrClientServerResponseUnreadable struct {
 	serverResponse []byte
+	contentType    string
 }
 
 func (err ErrClientServerResponseUnreadable) Error() string {
+	if err.contentType != "" {
+		return fmt.Sprintf("Server returned a response of type %q that could not be deserialized. Is the endpoint "+
+			"the PowerDNS API?", err.contentType)
+	}
 	return "Server returned a response that could not be deserialized"
 }
 
+// ContentType returns the Content-Type of the response if it was the reason the response could not be read, i.e.
+// the server sent something other than JSON (typically an HTML page) with a successful status.
+func (err ErrClientServerResponseUnreadable) ContentType() string {
+	return err.contentType
+}
+
 // ResponseBodyString returns the response body as a string.
 func (err ErrClientServerResponseUnreadable) ResponseBodyString() string {
 	if err.serverResponse != nil {
@@ -54,62 +169,151 @@ func (err ErrClientServerResponseUnreadable) ResponseBody() []byte {
 	return r
 }
 
+// ErrWrongDaemonType is returned when daemon type checking is enabled and a method is used against a server of the
+// wrong type, e.g. authoritative zone methods against a recursor.
+type ErrWrongDaemonType struct {
+	Expected shared.DaemonType
+	Actual   shared.DaemonType
+}
+
+func (err ErrWrongDaemonType) Error() string {
+	return fmt.Sprintf("Method requires a %s server but the server is a %s", err.Expected, err.Actual)
+}
+
 const (
 	apiPathString = "api/v1/"
+	// modulePath is the import path of this library, used to find its version in the build info.
+	modulePath = "github.com/wrouesnel/go.powerdns"
+	// develVersion is reported when the version of this library cannot be determined.
+	develVersion = "(devel)"
 )
 
-// resolveAPIPath modifies the given url to have an API path from the global constant.
-var resolveAPIPath func(u *url.URL) *url.URL
+// version is the version of this library, sent in the default User-Agent. Builds may set it with
+// -ldflags "-X github.com/wrouesnel/go.powerdns.version=v1.2.3", as the Makefile does; otherwise libraryVersion
+// finds it in the build info of the program.
+var version string
+
+// libraryVersion returns the version of this library: version if set at link time, else the version of the
+// module recorded in the program's build info, which is only known when the program is built in module mode.
+func libraryVersion() string {
+	if version != "" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return develVersion
+	}
+	modules := append([]*debug.Module{&info.Main}, info.Deps...)
+	for _, module := range modules {
+		if module.Path != modulePath {
+			continue
+		}
+		if module.Replace != nil && module.Replace.Version != "" {
+			return module.Replace.Version
+		}
+		if module.Version != "" {
+			return module.Version
+		}
+	}
+	return develVersion
+}
 
-func init() {
-	apiSubPath, err := url.Parse(apiPathString)
+// parseAPIPath parses the path of the API relative to the endpoint. A trailing slash is added if missing, since
+// otherwise the last path segment would be replaced when request paths are resolved against it.
+func parseAPIPath(apiPath string) (*url.URL, error) {
+	if !strings.HasSuffix(apiPath, "/") {
+		apiPath += "/"
+	}
+
+	apiSubPath, err := url.Parse(apiPath)
 	if err != nil {
-		panic(err)
+		return nil, errs.Wrap(ErrClientSubPathError, err)
 	}
-	resolveAPIPath = func(u *url.URL) *url.URL {
-		return u.ResolveReference(apiSubPath)
+
+	if apiSubPath.IsAbs() {
+		return nil, ErrClientRequestIsAbs
 	}
+
+	return apiSubPath, nil
 }
 
-// Client client struct
+// Logger receives a summary of each request sent by the Client. url never includes credentials, which are sent in
+// headers. status is zero if no response was received, in which case err says why.
+type Logger interface {
+	LogRequest(method, url string, status int, dur time.Duration, err error)
+}
+
+// MetricsObserver receives measurements of each request sent by the Client, suitable for recording as metrics.
+// endpointClass is a low-cardinality name for the kind of resource requested, such as "zones", "cryptokeys" or
+// "metadata". status is zero if no response was received.
+type MetricsObserver interface {
+	ObserveRequest(method, endpointClass string, status int, dur time.Duration, err error)
+}
+
+// ResponseHook is called with every response the Client receives, and the request it answers, before the body is
+// read. It must not read or close the body.
+type ResponseHook func(req *http.Request, resp *http.Response)
+
+// Client client struct. A Client is safe for concurrent use by multiple goroutines: its settings, including the
+// headers given to New, are fixed when it is constructed, and WithDaemonTypeCheck and ForServer return modified
+// copies.
 type Client struct {
 	endpoint   *url.URL
+	server     string
+	apiPath    *url.URL // API path relative to the endpoint, e.g. "api/v1/".
 	serverPath *url.URL // Server endpoint is added to match the multi-server functionality of pdns.
 	headers    http.Header
 	cli        *http.Client
+	// timeout is the deadline applied to each request. Zero means no deadline beyond that of the caller's context.
+	timeout time.Duration
+
+	// checkDaemonType enables verifying the daemon type before zone operations.
+	checkDaemonType bool
+	// serverInfo caches the server's info, for its daemon type and version. It is shared by shallow copies of the
+	// client.
+	serverInfo *serverInfoCache
+
+	// logger, if set, is told about every request.
+	logger Logger
+	// trace, if set, receives a copy of every request and response.
+	trace io.Writer
+	// apiKeyHeader is the header carrying the API key, if it is not one of the usual ones, so that traces redact it.
+	apiKeyHeader string
+	// metrics, if set, observes every request.
+	metrics MetricsObserver
+	// tracer, if set, wraps every request in a span.
+	tracer Tracer
+	// limiter, if set, limits the rate requests are sent at.
+	limiter *rate.Limiter
+	// maxResponseBytes, if positive, limits the size of response bodies.
+	maxResponseBytes int64
+	// responseHook, if set, is called with every response.
+	responseHook ResponseHook
+	// breaker, if set, stops requests while the server is failing. It is shared by shallow copies of the client.
+	breaker *circuitBreaker
+	// autoFQDN makes names fully qualified in the zone create and patch methods.
+	autoFQDN bool
 }
 
-// deadlineRoundTripper utility function lifted from prometheus.httputil with a few modifications
-func deadlineRoundTripper(timeout time.Duration, proxyURL *url.URL, tlsInsecure bool) http.RoundTripper {
+// deadlineRoundTripper utility function lifted from prometheus.httputil with a few modifications. The overall
+// request deadline is enforced by the Client with a context, which (unlike a deadline on the underlying connection)
+// allows connections to be reused, so only dialing is bounded here.
+func deadlineRoundTripper(timeout time.Duration, proxyURL *url.URL, tlsConfig *tls.Config,
+	keepAlives bool) http.RoundTripper {
 	return &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: tlsInsecure}, // nolint: gas
+		TLSClientConfig: tlsConfig,
 		// Set proxy (if null, then becomes a direct connection)
-		Proxy: http.ProxyURL(proxyURL),
-		// We need to disable keepalive, because we set a deadline on the
-		// underlying connection.
-		DisableKeepAlives: true,
-		Dial: func(netw, addr string) (c net.Conn, err error) {
-			start := time.Now()
-
-			c, err = net.DialTimeout(netw, addr, timeout)
-			if err != nil {
-				return nil, err
-			}
-
-			if err = c.SetDeadline(start.Add(timeout)); err != nil {
-				c.Close()
-				return nil, err
-			}
-
-			return c, nil
-		},
+		Proxy:             http.ProxyURL(proxyURL),
+		DisableKeepAlives: !keepAlives,
+		DialContext:       (&net.Dialer{Timeout: timeout}).DialContext,
 	}
 }
 
 // NewClient initializes an API client with some common defaults.
 func NewClient(endpoint string, apiKey string, tlsInsecure bool, timeout time.Duration) (*Client, error) {
 	// TLS conf
-	tr := deadlineRoundTripper(timeout, nil, tlsInsecure)
+	tr := deadlineRoundTripper(timeout, nil, &tls.Config{InsecureSkipVerify: tlsInsecure}, false) // nolint: gas
 	client := &http.Client{Transport: tr}
 
 	// Decode the url
@@ -121,25 +325,51 @@ func NewClient(endpoint string, apiKey string, tlsInsecure bool, timeout time.Du
 	// Set API key
 	headers := http.Header{}
 	headers["X-API-Key"] = []string{apiKey}
+	headers["User-Agent"] = []string{defaultUserAgent()}
+
+	apiClient, err := New(decodedURL, "localhost", client, headers)
+	if err != nil {
+		return nil, err
+	}
+	apiClient.timeout = timeout
 
-	return New(decodedURL, "localhost", client, headers)
+	return apiClient, nil
 }
 
 // New returns a New PowerDNS API client. If cli is set to nil, the default httpClient
 // is used (this will probably not work as you need to set an API key header - its also advisable to
-// configure connection time outs).
+// configure connection time outs). headers is copied, so later changes to it do not affect the client.
 func New(endpoint *url.URL, server string, cli *http.Client, headers http.Header) (*Client, error) {
 	if endpoint == nil {
 		return nil, ErrClientNilError
 	}
 
+	if (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, ErrClientInvalidEndpoint
+	}
+
 	if cli == nil {
 		cli = http.DefaultClient
 	}
 
+	// Paths are resolved relative to the endpoint, which drops its last segment unless it ends with a slash.
+	if !strings.HasSuffix(endpoint.Path, "/") {
+		normalized := *endpoint
+		normalized.Path += "/"
+		if normalized.RawPath != "" {
+			normalized.RawPath += "/"
+		}
+		endpoint = &normalized
+	}
+
+	apiPath, err := parseAPIPath(apiPathString)
+	if err != nil {
+		return nil, err
+	}
+
 	serverPath, err := url.Parse(fmt.Sprintf("servers/%s/", server))
 	if err != nil {
-		return nil, errwrap.Wrap(ErrClientSubPathError, err)
+		return nil, errs.Wrap(ErrClientSubPathError, err)
 	}
 
 	if serverPath.IsAbs() {
@@ -148,14 +378,22 @@ func New(endpoint *url.URL, server string, cli *http.Client, headers http.Header
 
 	apiClient := &Client{
 		endpoint:   endpoint,
+		apiPath:    apiPath,
+		server:     server,
 		serverPath: serverPath,
-		headers:    headers,
+		headers:    headers.Clone(),
 		cli:        cli,
+		serverInfo: &serverInfoCache{},
 	}
 
 	return apiClient, nil
 }
 
+// resolveAPIPath resolves the client's API path against the given url.
+func (p *Client) resolveAPIPath(u *url.URL) *url.URL {
+	return u.ResolveReference(p.apiPath)
+}
+
 // resolveServerPath adds the configured server path component to the endpoing URL
 func (p *Client) resolveServerPath(u *url.URL) *url.URL {
 	return u.ResolveReference(p.serverPath)
@@ -163,7 +401,13 @@ func (p *Client) resolveServerPath(u *url.URL) *url.URL {
 
 // resolveRequestPath wraps all the logic needed to resolve the full URI to send a given request to a server
 func (p *Client) resolveRequestPath(u *url.URL) *url.URL {
-	return p.resolveServerPath(resolveAPIPath(p.endpoint)).ResolveReference(u)
+	return p.resolveServerPath(p.resolveAPIPath(p.endpoint)).ResolveReference(u)
+}
+
+// resolveAPIRequestPath resolves the full URI of a request which is not specific to the configured server, i.e.
+// one relative to the API root rather than the server path.
+func (p *Client) resolveAPIRequestPath(u *url.URL) *url.URL {
+	return p.resolveAPIPath(p.endpoint).ResolveReference(u)
 }
 
 // DoRequest executes a generic request against a sub-path of the PowerDNS API.
@@ -171,78 +415,430 @@ func (p *Client) DoRequest(subPathStr string,
 	method string,
 	requestType interface{},
 	responseType interface{}) error {
+	return p.DoRequestContext(context.Background(), subPathStr, method, requestType, responseType)
+}
+
+// DoRequestContext executes a generic request against a sub-path of the PowerDNS API. The request is cancelled if
+// ctx is cancelled or its deadline (or the client's timeout) expires before the response body has been read.
+//
+// If responseType is a *[]byte or *string the response body is copied into it verbatim, which allows endpoints
+// that do not return JSON to be used. Otherwise the response is unmarshalled as JSON. The Accept header defaults
+// to application/json, but can be overridden by supplying an Accept header when constructing the client. A
+// successful response with an empty body, such as a 204 No Content, leaves responseType untouched.
+func (p *Client) DoRequestContext(ctx context.Context,
+	subPathStr string,
+	method string,
+	requestType interface{},
+	responseType interface{}) error {
+	return p.doDecodedRequest(ctx, p.resolveRequestPath, subPathStr, method, requestType, responseType)
+}
+
+// DoRequestRaw executes a request against a sub-path of the PowerDNS API and returns the raw JSON response and the
+// status code, for endpoints this library does not model. The status code is also returned alongside server errors.
+func (p *Client) DoRequestRaw(subPathStr string, method string, requestType interface{}) (json.RawMessage, int, error) {
+	return p.DoRequestRawContext(context.Background(), subPathStr, method, requestType)
+}
+
+// DoRequestRawContext is like DoRequestRaw but uses ctx for the request.
+func (p *Client) DoRequestRawContext(ctx context.Context,
+	subPathStr string,
+	method string,
+	requestType interface{}) (json.RawMessage, int, error) {
+	respBody, status, err := p.doRequest(ctx, p.resolveRequestPath, subPathStr, method, "application/json", true,
+		requestType)
+	if err != nil {
+		return nil, status, err
+	}
+	return json.RawMessage(respBody), status, nil
+}
+
+// doDecodedRequest executes a request against a sub-path resolved by resolve, and copies or unmarshals the
+// response into responseType as described for DoRequestContext.
+func (p *Client) doDecodedRequest(ctx context.Context,
+	resolve func(u *url.URL) *url.URL,
+	subPathStr string,
+	method string,
+	requestType interface{},
+	responseType interface{}) error {
+
+	expectJSON := true
+	switch responseType.(type) {
+	case nil, *[]byte, *string:
+		expectJSON = false
+	}
+
+	respBody, _, err := p.doRequest(ctx, resolve, subPathStr, method, p.acceptHeader(), expectJSON, requestType)
+	if err != nil {
+		return err
+	}
+
+	// Many endpoints answer with an empty body (usually 204 No Content), which is success with nothing to decode.
+	if len(bytes.TrimSpace(respBody)) == 0 {
+		return nil
+	}
+
+	// Success! Copy or unmarshal into the user type (if usertype supplied)
+	switch response := responseType.(type) {
+	case nil:
+	case *[]byte:
+		*response = respBody
+	case *string:
+		*response = string(respBody)
+	default:
+		if juerr := json.Unmarshal(respBody, responseType); juerr != nil {
+			return errs.Wrap(ErrClientServerResponseUnreadable{serverResponse: respBody}, juerr)
+		}
+	}
+
+	return nil
+}
+
+// acceptHeader returns the Accept header to send with generic requests.
+func (p *Client) acceptHeader() string {
+	if accept := p.headers.Get("Accept"); accept != "" {
+		return accept
+	}
+	return "application/json"
+}
+
+// doRequest sends a request to a sub-path resolved by resolve with the given Accept header, and returns the raw
+// body of a successful response and the status code. Error responses are decoded and returned as errors.
+func (p *Client) doRequest(ctx context.Context,
+	resolve func(u *url.URL) *url.URL,
+	subPathStr string,
+	method string,
+	accept string,
+	expectJSON bool,
+	requestType interface{}) ([]byte, int, error) {
+
+	body, status, err := p.openRequest(ctx, resolve, subPathStr, method, accept, expectJSON, requestType)
+	if err != nil {
+		return nil, status, err
+	}
+	defer body.Close() //nolint: errcheck
+
+	respBody, err := readBody(body)
+	return respBody, status, err
+}
+
+// readBody reads a response body, which may be limited by a limitedReadCloser.
+func readBody(body io.Reader) ([]byte, error) {
+	respBody, ierr := ioutil.ReadAll(body)
+	if ierr != nil {
+		if ierr == ErrClientResponseTooLarge {
+			return nil, ierr
+		}
+		return nil, errs.Wrap(ErrClientServerResponseUnreadable{serverResponse: respBody}, ierr)
+	}
+	return respBody, nil
+}
+
+// isJSONContentType returns whether contentType, the Content-Type of a response, allows it to be JSON. A missing
+// Content-Type is assumed to be JSON.
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// looksLikeJSON returns whether the first character of the body read by r, other than whitespace, could start a
+// JSON value. An empty body is allowed, since it is success with nothing to decode. Nothing is consumed from r.
+func looksLikeJSON(r *bufio.Reader) bool {
+	for size := 1; ; size++ {
+		peeked, err := r.Peek(size)
+		if len(peeked) < size {
+			return err == io.EOF
+		}
+		switch ch := peeked[size-1]; ch {
+		case ' ', '\t', '\r', '\n':
+			continue
+		default:
+			return strings.IndexByte(`[{"-0123456789tfn`, ch) >= 0
+		}
+	}
+}
+
+// limitedReadCloser returns ErrClientResponseTooLarge once more than remaining bytes have been read.
+type limitedReadCloser struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (r *limitedReadCloser) Read(b []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, ErrClientResponseTooLarge
+	}
+
+	// Read one byte past the limit so a body of exactly the limit is not rejected.
+	if int64(len(b)) > r.remaining+1 {
+		b = b[:r.remaining+1]
+	}
+	n, err := r.ReadCloser.Read(b)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n + int(r.remaining), ErrClientResponseTooLarge
+	}
+	return n, err
+}
+
+// cancelReadCloser cancels the context of a request once its response body is closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r cancelReadCloser) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// encodeRequestBody returns a reader which streams the JSON encoding of requestType as it is read. The returned
+// function stops the encoding if it is still in progress and returns any error encoding requestType; it must only
+// be called once the request has failed.
+func encodeRequestBody(requestType interface{}) (io.Reader, func() error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		err := json.NewEncoder(&trimNewlineWriter{w: pw}).Encode(requestType)
+		pw.CloseWithError(err) //nolint: errcheck
+		// The reader being closed early means the request failed, not the encoding.
+		if err == io.ErrClosedPipe {
+			err = nil
+		}
+		done <- err
+	}()
+
+	return pr, func() error {
+		pr.Close() //nolint: errcheck
+		return <-done
+	}
+}
+
+// trimNewlineWriter drops a trailing newline from what is written to it, so that json.Encoder sends exactly what
+// json.Marshal would. A newline is only written once more data follows it.
+type trimNewlineWriter struct {
+	w       io.Writer
+	pending bool
+}
+
+func (t *trimNewlineWriter) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	if t.pending {
+		if _, err := t.w.Write([]byte{'\n'}); err != nil {
+			return 0, err
+		}
+		t.pending = false
+	}
+
+	data := b
+	if b[len(b)-1] == '\n' {
+		data = b[:len(b)-1]
+		t.pending = true
+	}
+
+	if _, err := t.w.Write(data); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// openRequest sends a request as for doRequest, but returns the unread body of a successful response so it can be
+// decoded incrementally. The caller must close the body. The client's timeout covers reading the body. The status
+// code is returned whenever a response was received.
+func (p *Client) openRequest(ctx context.Context,
+	resolve func(u *url.URL) *url.URL,
+	subPathStr string,
+	method string,
+	accept string,
+	expectJSON bool,
+	requestType interface{}) (body io.ReadCloser, status int, err error) {
 
 	subPath, err := url.Parse(subPathStr)
 	if err != nil {
-		return errwrap.Wrap(ErrClientSubPathError, err)
+		return nil, status, errs.Wrap(ErrClientSubPathError, err)
 	}
 
 	if subPath.IsAbs() {
-		return ErrClientRequestIsAbs
+		return nil, status, ErrClientRequestIsAbs
+	}
+
+	requestPath := resolve(subPath)
+
+	// status is set once a response is received, for the benefit of tracing, logging and metrics.
+	if p.tracer != nil {
+		var span Span
+		ctx, span = p.tracer.StartSpan(ctx, fmt.Sprintf("PowerDNS %s %s", method, endpointClass(subPath)))
+		span.SetAttribute("http.method", method)
+		span.SetAttribute("http.url", requestPath.String())
+		if zone := zoneFromSubPath(subPath); zone != "" {
+			span.SetAttribute("powerdns.zone", zone)
+		}
+		defer func() {
+			if status != 0 {
+				span.SetAttribute("http.status_code", status)
+			}
+			span.End(err)
+		}()
+	}
+
+	// The rate limiter wait is not counted against the client's timeout, only the caller's context.
+	if p.limiter != nil {
+		if werr := p.limiter.Wait(ctx); werr != nil {
+			return nil, status, errs.Wrap(ErrClientRateLimitWait, werr)
+		}
 	}
 
-	// TODO: consider making resolveServerPath implicitly handle API path resolution
-	requestPath := p.resolveRequestPath(subPath)
+	if p.breaker != nil {
+		allowed, probe := p.breaker.allow()
+		if !allowed {
+			return nil, status, ErrCircuitOpen
+		}
+		callerCtx := ctx
+		defer func() {
+			p.breaker.record(probe, requestOutcome(callerCtx, status, err))
+		}()
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if p.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+	}
 
-	requestBody, jerr := json.Marshal(requestType)
-	if jerr != nil {
-		return errwrap.Wrap(ErrClientRequestParsingError, jerr)
+	// Request bodies are streamed as they are encoded, except when tracing needs the whole body, and for empty
+	// requests which are sent with a length.
+	var requestBody []byte
+	var bodyReader io.Reader
+	encodeErr := func() error { return nil }
+	if requestType == nil || p.trace != nil {
+		var jerr error
+		requestBody, jerr = json.Marshal(requestType)
+		if jerr != nil {
+			cancel()
+			return nil, status, errs.Wrap(ErrClientRequestParsingError, jerr)
+		}
+		bodyReader = bytes.NewReader(requestBody)
+	} else {
+		bodyReader, encodeErr = encodeRequestBody(requestType)
 	}
 
-	httpReq, rerr := http.NewRequest(method, requestPath.String(), bytes.NewBuffer(requestBody))
+	httpReq, rerr := http.NewRequestWithContext(ctx, method, requestPath.String(), bodyReader)
 	if rerr != nil {
-		return errwrap.Wrap(ErrClientRequestParsingError, rerr)
+		cancel()
+		encodeErr() //nolint: errcheck
+		return nil, status, errs.Wrap(ErrClientRequestParsingError, rerr)
 	}
 
-	// Add the headers.
+	// Add the headers. Each request gets its own copy, so nothing done to it can affect the client.
 	for key, values := range p.headers {
-		inputHeaders := values[:]
-		httpReq.Header[key] = inputHeaders
+		httpReq.Header[key] = append([]string(nil), values...)
 	}
 
-	// Forcibly set the JSON content type header and Accept header since the API requires it.
+	// Forcibly set the JSON content type header since the API requires it. Accept varies for the few endpoints
+	// which do not return JSON.
 	httpReq.Header["Content-Type"] = []string{"application/json"}
-	httpReq.Header["Accept"] = []string{"application/json"}
+	httpReq.Header["Accept"] = []string{accept}
 
 	// Execute the request.
+	if p.logger != nil || p.metrics != nil {
+		start := time.Now()
+		defer func() {
+			dur := time.Since(start)
+			if p.logger != nil {
+				p.logger.LogRequest(method, requestPath.String(), status, dur, err)
+			}
+			if p.metrics != nil {
+				p.metrics.ObserveRequest(method, endpointClass(subPath), status, dur, err)
+			}
+		}()
+	}
+
+	if p.trace != nil {
+		traceRequest(p.trace, httpReq, requestBody, p.apiKeyHeader)
+	}
+
 	resp, derr := p.cli.Do(httpReq)
 	if derr != nil {
-		return errwrap.Wrap(ErrClientRequestFailed, derr)
+		cancel()
+		// A request which could not be encoded fails while sending, but is reported as before it was sent.
+		if jerr := encodeErr(); jerr != nil {
+			return nil, status, errs.Wrap(ErrClientRequestParsingError, jerr)
+		}
+		return nil, status, errs.Wrap(ErrClientRequestFailed, derr)
 	}
 
-	// Deserialize the response.
-	defer resp.Body.Close() //nolint: errcheck
+	status = resp.StatusCode
+	if p.responseHook != nil {
+		p.responseHook(httpReq, resp)
+	}
+	if p.maxResponseBytes > 0 {
+		resp.Body = &limitedReadCloser{resp.Body, p.maxResponseBytes}
+	}
+	if p.trace != nil {
+		traceResponse(p.trace, resp)
+	}
 
-	respBody, ierr := ioutil.ReadAll(resp.Body)
-	if ierr != nil {
-		return errwrap.Wrap(ErrClientServerResponseUnreadable{respBody}, ierr)
-	}
-
-	// Check if an HTTP error code was returned, in which case we need to return an error type.
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		// Did not get 200, so we failed. Did we get a reported fail from the server?
-		if 400 <= resp.StatusCode && resp.StatusCode <= 599 {
-			// Should be able to unmarshal an error type.
-			responseErr := shared.Error{}
-			var wrappedErr error
-			if uerr := json.Unmarshal(respBody, &responseErr); uerr != nil {
-				wrappedErr = errwrap.Wrap(ErrClientServerResponseUnreadable{respBody}, uerr)
-			} else {
-				wrappedErr = responseErr
+	defer func() {
+		if body == nil {
+			cancel()
+			resp.Body.Close() //nolint: errcheck
+		}
+	}()
+
+	if 200 <= resp.StatusCode && resp.StatusCode <= 299 {
+		// A web page rather than the API answers with HTML, which is reported clearly rather than as bad JSON. Some
+		// proxies mislabel JSON, so the body is only rejected if it does not look like JSON either.
+		if contentType := resp.Header.Get("Content-Type"); expectJSON && !isJSONContentType(contentType) {
+			buffered := bufio.NewReader(resp.Body)
+			if !looksLikeJSON(buffered) {
+				respBody, ierr := readBody(buffered)
+				if ierr != nil {
+					return nil, status, ierr
+				}
+				return nil, status, ErrClientServerResponseUnreadable{serverResponse: respBody, contentType: contentType}
 			}
-			wrappedErr = errwrap.Wrap(ErrClientServerResponse, responseErr)
-			return wrappedErr
+			resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{buffered, resp.Body}
 		}
-		// Did not succeed, but did not recognize the status code either.
-		return ErrClientServerUnknownStatus
+		return cancelReadCloser{resp.Body, cancel}, status, nil
 	}
 
-	// Success! Unmarshal into the user type (if usertype supplied)
-	if responseType != nil {
-		if juerr := json.Unmarshal(respBody, responseType); juerr != nil {
-			return errwrap.Wrap(ErrClientServerResponseUnreadable{respBody}, juerr)
-		}
+	respBody, ierr := readBody(resp.Body)
+	if ierr != nil {
+		return nil, status, ierr
 	}
 
-	return nil
+	// Did not get 200, so we failed. Did we get a reported fail from the server?
+	if 400 <= resp.StatusCode && resp.StatusCode <= 599 {
+		// Should be able to unmarshal an error type.
+		responseErr := shared.Error{}
+		var decodedErr error
+		if uerr := json.Unmarshal(respBody, &responseErr); uerr != nil {
+			decodedErr = errs.Wrap(ErrClientServerResponseUnreadable{serverResponse: respBody}, uerr)
+		} else {
+			decodedErr = responseErr
+		}
+		wrappedErr := ServerError{statusCode: resp.StatusCode, body: respBody, Err: decodedErr}
+		// Missing resources are common enough that callers need to be able to distinguish them.
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return nil, status, errs.Wrap(ErrNotFound, wrappedErr)
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return nil, status, errs.Wrap(ErrUnauthorized, wrappedErr)
+		}
+		return nil, status, errs.Wrap(ErrClientServerResponse, wrappedErr)
+	}
+	// Did not succeed, but did not recognize the status code either.
+	return nil, status, errs.Wrap(ErrClientServerUnknownStatus,
+		ServerError{statusCode: resp.StatusCode, body: respBody})
 }
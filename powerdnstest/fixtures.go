@@ -0,0 +1,189 @@
+package powerdnstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/wrouesnel/go.powerdns/internal/errs"
+)
+
+// ErrNoFixture is returned (wrapped) by a Replayer when no unused fixture matches a request.
+var ErrNoFixture = errors.New("No recorded fixture matches the request") // nolint: golint
+
+// Fixture is a request and the response the server sent to it. Credentials are never recorded, and the endpoint
+// is left out of URL so fixtures can be replayed against any endpoint.
+type Fixture struct {
+	Method string `json:"method"`
+	// URL is the path and query of the request, e.g. "/api/v1/servers/localhost/zones?rrsets=false".
+	URL         string `json:"url"`
+	RequestBody string `json:"request_body,omitempty"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+}
+
+// LoadFixtures reads fixtures saved by Recorder.Save.
+func LoadFixtures(path string) ([]Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fixtures := []Fixture{}
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+// Recorder is an http.RoundTripper which sends requests with Transport and records each request and response as a
+// Fixture. It is safe for concurrent use.
+type Recorder struct {
+	// Transport sends the requests. http.DefaultTransport is used if it is nil.
+	Transport http.RoundTripper
+
+	mtx      sync.Mutex
+	fixtures []Fixture
+}
+
+// NewRecorder returns a Recorder which sends requests with transport.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	return &Recorder{Transport: transport}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	fixture := Fixture{Method: req.Method, URL: req.URL.RequestURI()}
+
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close() // nolint: errcheck
+		if err != nil {
+			return nil, err
+		}
+		fixture.RequestBody = string(body)
+
+		// The request must not be modified, so send a copy with the body restored.
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close() // nolint: errcheck
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	fixture.Status = resp.StatusCode
+	fixture.ContentType = resp.Header.Get("Content-Type")
+	fixture.Body = string(body)
+
+	r.mtx.Lock()
+	r.fixtures = append(r.fixtures, fixture)
+	r.mtx.Unlock()
+
+	return resp, nil
+}
+
+// Fixtures returns the fixtures recorded so far, in the order the responses were received.
+func (r *Recorder) Fixtures() []Fixture {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]Fixture{}, r.fixtures...)
+}
+
+// Save writes the fixtures recorded so far to path as JSON, for LoadFixtures.
+func (r *Recorder) Save(path string) error {
+	data, err := json.MarshalIndent(r.Fixtures(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Replayer is an http.RoundTripper which answers requests from recorded fixtures instead of a server. Each request
+// is answered by the first unused fixture with the same method and URL, so a sequence of identical requests gets
+// the recorded responses in order. It is safe for concurrent use.
+type Replayer struct {
+	mtx      sync.Mutex
+	fixtures []Fixture
+	used     []bool
+}
+
+// NewReplayer returns a Replayer which answers requests from fixtures.
+func NewReplayer(fixtures []Fixture) *Replayer {
+	return &Replayer{
+		fixtures: append([]Fixture{}, fixtures...),
+		used:     make([]bool, len(fixtures)),
+	}
+}
+
+// RoundTrip implements http.RoundTripper. A request no fixture matches fails with an error wrapping ErrNoFixture.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		io.Copy(ioutil.Discard, req.Body) // nolint: errcheck
+		req.Body.Close()                  // nolint: errcheck
+	}
+
+	url := req.URL.RequestURI()
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for idx, fixture := range r.fixtures {
+		if r.used[idx] || fixture.Method != req.Method || fixture.URL != url {
+			continue
+		}
+		r.used[idx] = true
+
+		header := http.Header{}
+		if fixture.ContentType != "" {
+			header.Set("Content-Type", fixture.ContentType)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", fixture.Status, http.StatusText(fixture.Status)),
+			StatusCode:    fixture.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(strings.NewReader(fixture.Body)),
+			ContentLength: int64(len(fixture.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, errs.Wrap(ErrNoFixture, fmt.Errorf("%s %s", req.Method, url))
+}
+
+// Unused returns the fixtures which have not yet answered a request, so a test can check all were used.
+func (r *Replayer) Unused() []Fixture {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	unused := []Fixture{}
+	for idx, fixture := range r.fixtures {
+		if !r.used[idx] {
+			unused = append(unused, fixture)
+		}
+	}
+	return unused
+}
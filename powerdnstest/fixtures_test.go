@@ -0,0 +1,106 @@
+package powerdnstest_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/errwrap"
+	. "gopkg.in/check.v1"
+
+	"github.com/wrouesnel/go.powerdns"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+	"github.com/wrouesnel/go.powerdns/powerdnstest"
+)
+
+type FixturesSuite struct{}
+
+var _ = Suite(&FixturesSuite{})
+
+// The endpoint is never contacted when replaying, so any URL will do.
+const replayEndpoint = "http://replay.invalid:8081/"
+
+func (s *FixturesSuite) TestRecordAndReplay(c *C) {
+	server := powerdnstest.NewServer()
+	defer server.Close()
+
+	recorder := powerdnstest.NewRecorder(http.DefaultTransport)
+	pdnsCli, err := powerdns.NewClientWithOptions(server.URL, "recorded-key", powerdns.WithTransport(recorder))
+	c.Assert(err, IsNil)
+
+	req := authoritative.ZoneRequestNative{
+		Zone:        authoritative.Zone{Zone: shared.Zone{Name: "recorded.zone."}, Kind: authoritative.KindNative},
+		Nameservers: []string{"ns1.recorded.zone."},
+	}
+	created, err := pdnsCli.CreateZone(req)
+	c.Assert(err, IsNil)
+	_, err = pdnsCli.CreateZone(req)
+	c.Assert(err, NotNil)
+
+	fixtures := recorder.Fixtures()
+	c.Assert(fixtures, HasLen, 2)
+	c.Assert(fixtures[0].Status, Equals, http.StatusCreated)
+	c.Assert(fixtures[1].Status, Equals, http.StatusConflict)
+
+	dir, err := ioutil.TempDir("", "powerdnstest")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	path := filepath.Join(dir, "fixtures.json")
+	c.Assert(recorder.Save(path), IsNil)
+	saved, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	// Credentials are never recorded.
+	c.Assert(string(saved), Not(Matches), "(?s).*recorded-key.*")
+
+	loaded, err := powerdnstest.LoadFixtures(path)
+	c.Assert(err, IsNil)
+	c.Assert(loaded, DeepEquals, fixtures)
+
+	replayer := powerdnstest.NewReplayer(loaded)
+	replayCli, err := powerdns.NewClientWithOptions(replayEndpoint, "", powerdns.WithTransport(replayer))
+	c.Assert(err, IsNil)
+
+	replayed, err := replayCli.CreateZone(req)
+	c.Assert(err, IsNil)
+	c.Assert(replayed, DeepEquals, created)
+	_, err = replayCli.CreateZone(req)
+	status, ok := powerdns.StatusCode(err)
+	c.Assert(ok, Equals, true)
+	c.Assert(status, Equals, http.StatusConflict)
+	c.Assert(replayer.Unused(), HasLen, 0)
+
+	// Every fixture has been used, so there is nothing left to answer with.
+	_, err = replayCli.CreateZone(req)
+	c.Assert(errwrap.Contains(err, powerdns.ErrClientRequestFailed.Error()), Equals, true)
+	urlErr, ok := errwrap.GetType(err, &url.Error{}).(*url.Error)
+	c.Assert(ok, Equals, true)
+	c.Assert(errwrap.Contains(urlErr.Err, powerdnstest.ErrNoFixture.Error()), Equals, true)
+}
+
+func (s *FixturesSuite) TestReplayErrorFixtures(c *C) {
+	fixtures, err := powerdnstest.LoadFixtures("testdata/errors.json")
+	c.Assert(err, IsNil)
+
+	pdnsCli, err := powerdns.NewClientWithOptions(replayEndpoint, "",
+		powerdns.WithTransport(powerdnstest.NewReplayer(fixtures)))
+	c.Assert(err, IsNil)
+
+	err = pdnsCli.UpsertRecord("example.org.", "www.example.com.", "A", 300, "192.0.2.1")
+	c.Assert(errwrap.Contains(err, powerdns.ErrClientServerResponse.Error()), Equals, true)
+	c.Assert(errwrap.Contains(err, "RRset www.example.com. IN A: Name is out of zone"), Equals, true)
+	status, _ := powerdns.StatusCode(err)
+	c.Assert(status, Equals, http.StatusUnprocessableEntity)
+
+	_, err = pdnsCli.CreateZone(authoritative.ZoneRequestNative{
+		Zone: authoritative.Zone{Zone: shared.Zone{Name: "example.org."}, Kind: authoritative.KindNative},
+	})
+	c.Assert(errwrap.Contains(err, "Domain 'example.org.' already exists"), Equals, true)
+
+	_, err = pdnsCli.GetZone("missing.org.")
+	c.Assert(powerdns.IsNotFound(err), Equals, true)
+	c.Assert(errwrap.Contains(err, "Could not find domain 'missing.org.'"), Equals, true)
+}
@@ -0,0 +1,699 @@
+// Package powerdnstest provides an in-memory fake of the PowerDNS authoritative server API, so that code using the
+// powerdns client can be unit tested without running a real server.
+//
+// The fake implements the server info, zone, cryptokey, metadata and TSIG key endpoints closely enough for the
+// client's zone and record methods, including the error responses PowerDNS sends for missing or conflicting zones.
+// It does not serve DNS, and does not attempt to reproduce every validation the real server performs.
+package powerdnstest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+const (
+	// ServerID is the only server the fake serves.
+	ServerID = "localhost"
+	// Version is the PowerDNS version the fake reports.
+	Version = "4.8.0"
+
+	serverPath   = "/api/v1/servers/" + ServerID
+	zonesPath    = serverPath + "/zones"
+	tsigKeysPath = serverPath + "/tsigkeys"
+)
+
+// NewServer starts and returns a fake PowerDNS authoritative server with no zones. Requests are not authenticated,
+// so any API key may be used. The caller should Close the server when done.
+func NewServer() *httptest.Server {
+	return httptest.NewServer(NewHandler())
+}
+
+// NewHandler returns the http.Handler which implements the fake server, for use with a server started by the
+// caller.
+func NewHandler() http.Handler {
+	return &fakeServer{zones: map[string]*fakeZone{}, tsigKeys: map[string]authoritative.TSIGKey{}}
+}
+
+// fakeZone is a zone held by the fake server.
+type fakeZone struct {
+	zone       authoritative.ZoneResponse
+	cryptokeys []authoritative.Cryptokey
+	nextKeyID  int
+	metadata   map[string][]string
+}
+
+// fakeServer holds the state of the fake server. Zones are indexed by their lowercased name, and TSIG keys by ID.
+type fakeServer struct {
+	mtx      sync.Mutex
+	zones    map[string]*fakeZone
+	tsigKeys map[string]authoritative.TSIGKey
+}
+
+// writeJSON writes v as the JSON response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v) // nolint: errcheck
+}
+
+// writeError writes a PowerDNS style error response.
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, shared.Error{Message: fmt.Sprintf(format, args...)})
+}
+
+// decodeZoneID reverses the "=XX" escaping PowerDNS uses for zone IDs in URLs.
+func decodeZoneID(id string) (string, bool) {
+	name := make([]byte, 0, len(id))
+	for i := 0; i < len(id); i++ {
+		if id[i] != '=' {
+			name = append(name, id[i])
+			continue
+		}
+		if i+2 >= len(id) {
+			return "", false
+		}
+		ch, err := strconv.ParseUint(id[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", false
+		}
+		name = append(name, byte(ch))
+		i += 2
+	}
+	return string(name), true
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	path := r.URL.Path
+	switch {
+	case path == "/api/v1/servers":
+		s.serveServers(w, r)
+	case path == serverPath:
+		s.serveServer(w, r)
+	case path == zonesPath:
+		s.serveZones(w, r)
+	case path == tsigKeysPath:
+		s.serveTSIGKeys(w, r)
+	case strings.HasPrefix(path, tsigKeysPath+"/"):
+		s.serveTSIGKey(w, r, strings.TrimPrefix(path, tsigKeysPath+"/"))
+	case strings.HasPrefix(path, zonesPath+"/"):
+		parts := strings.SplitN(strings.TrimPrefix(path, zonesPath+"/"), "/", 2)
+		name, ok := decodeZoneID(parts[0])
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		zone, found := s.zones[strings.ToLower(name)]
+		if !found {
+			writeError(w, http.StatusNotFound, "Could not find domain '%s'", name)
+			return
+		}
+		if len(parts) == 1 {
+			s.serveZone(w, r, zone)
+		} else {
+			s.serveZoneAction(w, r, zone, parts[1])
+		}
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (s *fakeServer) serverInfo() shared.ServerInfo {
+	return shared.ServerInfo{
+		ConfigURL:  serverPath + "/config{/config_setting}",
+		DaemonType: shared.DaemonTypeAuthoritative,
+		ID:         ServerID,
+		Type:       "Server",
+		URL:        serverPath,
+		Version:    Version,
+		ZonesURL:   zonesPath + "{/zone}",
+	}
+}
+
+func (s *fakeServer) serveServers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, []shared.ServerInfo{s.serverInfo()})
+}
+
+func (s *fakeServer) serveServer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.serverInfo())
+}
+
+// zoneRequest decodes the fields of any of the zone creation requests.
+type zoneRequest struct {
+	authoritative.Zone
+	Nameservers []string `json:"nameservers"`
+	Masters     []string `json:"masters"`
+}
+
+func (s *fakeServer) serveZones(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		filter := strings.ToLower(r.URL.Query().Get("zone"))
+		includeRRsets := r.URL.Query().Get("rrsets") != "false"
+
+		names := make([]string, 0, len(s.zones))
+		for name := range s.zones {
+			if filter == "" || name == filter {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		result := make([]authoritative.ZoneResponse, 0, len(names))
+		for _, name := range names {
+			zone := s.zones[name].zone
+			zone.Zone = zone.Zone.Copy()
+			if !includeRRsets {
+				zone.RRsets = nil
+			}
+			result = append(result, zone)
+		}
+		writeJSON(w, http.StatusOK, result)
+
+	case http.MethodPost:
+		req := zoneRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON: %v", err)
+			return
+		}
+		s.createZone(w, req)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (s *fakeServer) createZone(w http.ResponseWriter, req zoneRequest) {
+	req.Zone.Normalize()
+	if err := req.Zone.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Unable to parse DNS Name '%s'", req.Name)
+		return
+	}
+	if _, found := s.zones[req.Name]; found {
+		writeError(w, http.StatusConflict, "Domain '%s' already exists", req.Name)
+		return
+	}
+
+	if req.Kind == "" {
+		req.Kind = authoritative.KindNative
+	}
+	if !req.Kind.IsValid() {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid zone kind '%s'", req.Kind)
+		return
+	}
+
+	rrsets := req.RRsets.Copy()
+	for idx := range rrsets {
+		if !inZone(rrsets[idx].Name, req.Name) {
+			writeError(w, http.StatusUnprocessableEntity, "RRset %s IN %s: Name is out of zone", rrsets[idx].Name,
+				rrsets[idx].Type)
+			return
+		}
+	}
+
+	if len(req.Nameservers) > 0 {
+		if hasRRset(rrsets, req.Name, "NS") {
+			writeError(w, http.StatusUnprocessableEntity,
+				"Nameservers list MUST NOT be mixed with zone-level NS in rrsets")
+			return
+		}
+		ns := shared.RRset{Name: req.Name, Type: "NS", TTL: 3600}
+		for _, nameserver := range req.Nameservers {
+			ns.Records = append(ns.Records, shared.Record{Content: nameserver})
+		}
+		rrsets = append(rrsets, ns)
+	}
+
+	if !hasRRset(rrsets, req.Name, "SOA") {
+		rrsets = append(rrsets, shared.RRset{Name: req.Name, Type: "SOA", TTL: 3600, Records: shared.Records{{
+			Content: fmt.Sprintf("a.misconfigured.dns.server.invalid. hostmaster.%s 0 10800 3600 604800 3600",
+				req.Name),
+		}}})
+	}
+
+	zone := &fakeZone{nextKeyID: 1, metadata: map[string][]string{}}
+	zone.zone.Zone = req.Zone
+	zone.zone.RRsets = rrsets
+	zone.zone.URL = zonesPath + "/" + req.Name
+	zone.zone.Serial = 1
+	zone.zone.Masters = req.Masters
+	s.zones[req.Name] = zone
+
+	writeJSON(w, http.StatusCreated, zone.zone)
+}
+
+// hasRRset returns whether rrsets contains an RRset of the given name and type.
+func hasRRset(rrsets shared.RRsets, name, rrtype string) bool {
+	for _, rrset := range rrsets {
+		if strings.EqualFold(rrset.Name, name) && strings.EqualFold(rrset.Type, rrtype) {
+			return true
+		}
+	}
+	return false
+}
+
+// inZone returns whether name is at or below the apex of zone. Both must be fully qualified.
+func inZone(name, zone string) bool {
+	name = strings.ToLower(name)
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+func (s *fakeServer) serveZone(w http.ResponseWriter, r *http.Request, zone *fakeZone) {
+	switch r.Method {
+	case http.MethodGet:
+		result := zone.zone
+		result.Zone = result.Zone.Copy()
+
+		query := r.URL.Query()
+		switch {
+		case query.Get("rrsets") == "false":
+			result.RRsets = nil
+		case query.Get("rrset_name") != "":
+			filtered := shared.RRsets{}
+			for _, rrset := range result.RRsets {
+				if strings.EqualFold(rrset.Name, query.Get("rrset_name")) &&
+					(query.Get("rrset_type") == "" || strings.EqualFold(rrset.Type, query.Get("rrset_type"))) {
+					filtered = append(filtered, rrset)
+				}
+			}
+			result.RRsets = filtered
+		}
+		writeJSON(w, http.StatusOK, result)
+
+	case http.MethodPut:
+		update := authoritative.ZoneUpdate{}
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON: %v", err)
+			return
+		}
+		if update.Kind != nil && !update.Kind.IsValid() {
+			writeError(w, http.StatusUnprocessableEntity, "Invalid zone kind '%s'", *update.Kind)
+			return
+		}
+		s.updateZone(zone, update)
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodPatch:
+		req := authoritative.PatchZoneRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON: %v", err)
+			return
+		}
+		if msg := s.patchZone(zone, req.RRSets); msg != "" {
+			writeError(w, http.StatusUnprocessableEntity, "%s", msg)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		delete(s.zones, strings.ToLower(zone.zone.Name))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (s *fakeServer) updateZone(zone *fakeZone, update authoritative.ZoneUpdate) {
+	if update.Kind != nil {
+		zone.zone.Kind = *update.Kind
+	}
+	if update.DNSsec != nil {
+		zone.zone.DNSsec = *update.DNSsec
+	}
+	if update.SoaEdit != nil {
+		zone.zone.SoaEdit = *update.SoaEdit
+	}
+	if update.SoaEditAPI != nil {
+		zone.zone.SoaEditAPI = *update.SoaEditAPI
+	}
+	if update.Account != nil {
+		zone.zone.Account = *update.Account
+	}
+	if update.NSEC3Param != nil {
+		zone.zone.NSEC3Param = *update.NSEC3Param
+	}
+	if update.NSEC3Narrow != nil {
+		zone.zone.NSEC3Narrow = *update.NSEC3Narrow
+	}
+	if update.Presigned != nil {
+		zone.zone.Presigned = *update.Presigned
+	}
+	if update.Masters != nil {
+		zone.zone.Masters = append([]string{}, *update.Masters...)
+	}
+}
+
+// patchZone applies changes to zone, returning the error message to send if they are invalid. No change is applied
+// unless all are valid.
+func (s *fakeServer) patchZone(zone *fakeZone, changes authoritative.PatchRRSets) string {
+	rrsets := zone.zone.RRsets.ToMap()
+	order := zone.zone.RRsets.Copy()
+
+	for _, change := range changes {
+		rrset := change.CopyToRRSet()
+		if !inZone(rrset.Name, zone.zone.Name) {
+			return fmt.Sprintf("RRset %s IN %s: Name is out of zone", rrset.Name, rrset.Type)
+		}
+
+		name := rrset.UniqueName()
+		switch change.ChangeType {
+		case authoritative.RRsetReplace:
+			current, found := rrsets[name]
+			switch {
+			case change.Records == nil:
+				// Only the comments are replaced, as when setting comments on an existing RRset.
+				if found && change.Comments != nil {
+					current.Comments = rrset.Comments
+					rrsets[name] = current
+				}
+				continue
+			case len(change.Records) == 0:
+				delete(rrsets, name)
+				continue
+			}
+			// Likewise the comments are kept unless new ones are given.
+			if found && change.Comments == nil {
+				rrset.Comments = current.Comments
+			}
+			if !found {
+				order = append(order, rrset)
+			}
+			rrsets[name] = rrset
+		case authoritative.RRSetDelete:
+			delete(rrsets, name)
+		default:
+			return "Changetype not understood"
+		}
+	}
+
+	result := shared.RRsets{}
+	for _, rrset := range order {
+		if current, found := rrsets[rrset.UniqueName()]; found {
+			result = append(result, current)
+			delete(rrsets, rrset.UniqueName())
+		}
+	}
+	zone.zone.RRsets = result
+	zone.zone.Serial++
+	return ""
+}
+
+func (s *fakeServer) serveZoneAction(w http.ResponseWriter, r *http.Request, zone *fakeZone, action string) {
+	switch {
+	case action == "notify" && r.Method == http.MethodPut:
+		if zone.zone.Kind == authoritative.KindSlave {
+			writeError(w, http.StatusUnprocessableEntity, "Domain '%s' is not a master or native domain",
+				zone.zone.Name)
+			return
+		}
+		writeJSON(w, http.StatusOK, shared.ActionResult{Result: "Notification queued"})
+	case action == "rectify" && r.Method == http.MethodPut:
+		writeJSON(w, http.StatusOK, shared.ActionResult{Result: "Rectified"})
+	case action == "export" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/plain")
+		for _, rrset := range zone.zone.RRsets {
+			for _, record := range rrset.Records {
+				fmt.Fprintf(w, "%s\t%d\tIN\t%s\t%s\n", rrset.Name, rrset.TTL, rrset.Type, record.Content)
+			}
+		}
+	case action == "cryptokeys":
+		s.serveCryptokeys(w, r, zone)
+	case strings.HasPrefix(action, "cryptokeys/"):
+		id, err := strconv.Atoi(strings.TrimPrefix(action, "cryptokeys/"))
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		s.serveCryptokey(w, r, zone, id)
+	case action == "metadata":
+		s.serveMetadataList(w, r, zone)
+	case strings.HasPrefix(action, "metadata/"):
+		s.serveMetadata(w, r, zone, strings.ToUpper(strings.TrimPrefix(action, "metadata/")))
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (s *fakeServer) serveCryptokeys(w http.ResponseWriter, r *http.Request, zone *fakeZone) {
+	switch r.Method {
+	case http.MethodGet:
+		// Private keys are only included when a single key is requested.
+		keys := make([]authoritative.Cryptokey, 0, len(zone.cryptokeys))
+		for _, key := range zone.cryptokeys {
+			key.PrivateKey = ""
+			keys = append(keys, key)
+		}
+		writeJSON(w, http.StatusOK, keys)
+
+	case http.MethodPost:
+		key := authoritative.Cryptokey{}
+		if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON: %v", err)
+			return
+		}
+		if key.KeyType == "" {
+			key.KeyType = "csk"
+		}
+		if key.Algorithm == "" {
+			key.Algorithm = "ECDSAP256SHA256"
+			key.Bits = 256
+		}
+		key.Type = "Cryptokey"
+		key.ID = zone.nextKeyID
+		if key.PrivateKey == "" {
+			key.PrivateKey = fmt.Sprintf("Private-key-format: v1.2\nAlgorithm: 13 (ECDSAP256SHA256)\n"+
+				"PrivateKey: %s\n", base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s%d", zone.zone.Name, key.ID))))
+		}
+		// The public key is derived from the private key, so an imported key keeps its DNSKEY.
+		key.DNSKey = "257 3 13 " + base64.StdEncoding.EncodeToString([]byte(key.PrivateKey))
+		zone.nextKeyID++
+
+		zone.cryptokeys = append(zone.cryptokeys, key)
+		zone.zone.DNSsec = true
+		writeJSON(w, http.StatusCreated, key)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (s *fakeServer) serveCryptokey(w http.ResponseWriter, r *http.Request, zone *fakeZone, id int) {
+	idx := -1
+	for i := range zone.cryptokeys {
+		if zone.cryptokeys[i].ID == id {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		writeError(w, http.StatusNotFound, "Could not find cryptokey with id %d", id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, zone.cryptokeys[idx])
+
+	case http.MethodPut:
+		key := authoritative.Cryptokey{}
+		if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON: %v", err)
+			return
+		}
+		zone.cryptokeys[idx].Active = key.Active
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		zone.cryptokeys = append(zone.cryptokeys[:idx], zone.cryptokeys[idx+1:]...)
+		zone.zone.DNSsec = len(zone.cryptokeys) > 0
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// protectedMetadataKinds are the metadata kinds PowerDNS does not allow to be changed through the API.
+var protectedMetadataKinds = map[string]bool{
+	"API-RECTIFY":      true,
+	"AXFR-MASTER-TSIG": true,
+	"LUA-AXFR-SCRIPT":  true,
+	"NSEC3NARROW":      true,
+	"NSEC3PARAM":       true,
+	"PRESIGNED":        true,
+	"SOA-EDIT-API":     true,
+	"TSIG-ALLOW-AXFR":  true,
+}
+
+func (s *fakeServer) serveMetadataList(w http.ResponseWriter, r *http.Request, zone *fakeZone) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	kinds := make([]string, 0, len(zone.metadata))
+	for kind := range zone.metadata {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	result := make([]authoritative.Metadata, 0, len(kinds))
+	for _, kind := range kinds {
+		result = append(result, authoritative.Metadata{Kind: kind, Metadata: zone.metadata[kind]})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *fakeServer) serveMetadata(w http.ResponseWriter, r *http.Request, zone *fakeZone, kind string) {
+	if r.Method != http.MethodGet && protectedMetadataKinds[kind] {
+		writeError(w, http.StatusUnprocessableEntity, "Metadata kind '%s' cannot be modified via the API", kind)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		values, found := zone.metadata[kind]
+		if !found {
+			values = []string{}
+		}
+		writeJSON(w, http.StatusOK, authoritative.Metadata{Kind: kind, Metadata: values})
+
+	case http.MethodPut:
+		req := authoritative.Metadata{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON: %v", err)
+			return
+		}
+		values := append([]string{}, req.Metadata...)
+		if len(values) == 0 {
+			delete(zone.metadata, kind)
+		} else {
+			zone.metadata[kind] = values
+		}
+		writeJSON(w, http.StatusOK, authoritative.Metadata{Kind: kind, Metadata: values})
+
+	case http.MethodDelete:
+		delete(zone.metadata, kind)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// tsigKeyID returns the ID PowerDNS gives a TSIG key with the given name.
+func tsigKeyID(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
+
+func (s *fakeServer) serveTSIGKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		ids := make([]string, 0, len(s.tsigKeys))
+		for id := range s.tsigKeys {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		// Key material is only included when a single key is requested.
+		keys := make([]authoritative.TSIGKey, 0, len(ids))
+		for _, id := range ids {
+			key := s.tsigKeys[id]
+			key.Key = ""
+			keys = append(keys, key)
+		}
+		writeJSON(w, http.StatusOK, keys)
+
+	case http.MethodPost:
+		key := authoritative.TSIGKey{}
+		if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON: %v", err)
+			return
+		}
+		if key.Name == "" {
+			writeError(w, http.StatusUnprocessableEntity, "TSIG key name must not be empty")
+			return
+		}
+		key.ID = tsigKeyID(key.Name)
+		if _, found := s.tsigKeys[key.ID]; found {
+			writeError(w, http.StatusConflict, "A TSIG key with the name '%s' already exists", key.Name)
+			return
+		}
+		if key.Algorithm == "" {
+			key.Algorithm = "hmac-md5"
+		}
+		if key.Key == "" {
+			key.Key = base64.StdEncoding.EncodeToString([]byte("fake-" + key.ID))
+		}
+		key.Type = "TSIGKey"
+
+		s.tsigKeys[key.ID] = key
+		writeJSON(w, http.StatusCreated, key)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (s *fakeServer) serveTSIGKey(w http.ResponseWriter, r *http.Request, id string) {
+	key, found := s.tsigKeys[id]
+	if !found {
+		writeError(w, http.StatusNotFound, "TSIG key with name '%s' not found", id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, key)
+
+	case http.MethodPut:
+		update := authoritative.TSIGKey{}
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON: %v", err)
+			return
+		}
+		if update.Algorithm != "" {
+			key.Algorithm = update.Algorithm
+		}
+		if update.Key != "" {
+			key.Key = update.Key
+		}
+		if update.Name != "" && tsigKeyID(update.Name) != id {
+			if _, found := s.tsigKeys[tsigKeyID(update.Name)]; found {
+				writeError(w, http.StatusConflict, "A TSIG key with the name '%s' already exists", update.Name)
+				return
+			}
+			delete(s.tsigKeys, id)
+			key.Name, key.ID = update.Name, tsigKeyID(update.Name)
+		}
+		s.tsigKeys[key.ID] = key
+		writeJSON(w, http.StatusOK, key)
+
+	case http.MethodDelete:
+		delete(s.tsigKeys, id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -0,0 +1,127 @@
+package powerdnstest_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/wrouesnel/go.powerdns"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+	"github.com/wrouesnel/go.powerdns/powerdnstest"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type ServerSuite struct {
+	server  *httptest.Server
+	pdnsCli *powerdns.Client
+}
+
+var _ = Suite(&ServerSuite{})
+
+func (s *ServerSuite) SetUpTest(c *C) {
+	s.server = powerdnstest.NewServer()
+
+	var err error
+	s.pdnsCli, err = powerdns.NewClient(s.server.URL, "any-key", false, 0)
+	c.Assert(err, IsNil)
+	s.pdnsCli = s.pdnsCli.WithDaemonTypeCheck()
+}
+
+func (s *ServerSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *ServerSuite) TestServerInfo(c *C) {
+	info, err := s.pdnsCli.ServerInfo()
+	c.Assert(err, IsNil)
+	c.Assert(info.DaemonType, Equals, shared.DaemonType(shared.DaemonTypeAuthoritative))
+	c.Assert(info.Version, Equals, powerdnstest.Version)
+}
+
+func (s *ServerSuite) TestZoneLifecycle(c *C) {
+	zone, err := s.pdnsCli.CreateZone(authoritative.ZoneRequestNative{
+		Zone:        authoritative.Zone{Zone: shared.Zone{Name: "Fake.Zone"}, Kind: authoritative.KindNative},
+		Nameservers: []string{"ns1.fake.zone."},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(zone.Name, Equals, "fake.zone.")
+	c.Assert(zone.Serial, Equals, uint32(1))
+
+	_, err = s.pdnsCli.CreateZone(authoritative.ZoneRequestNative{
+		Zone: authoritative.Zone{Zone: shared.Zone{Name: "fake.zone."}, Kind: authoritative.KindNative},
+	})
+	c.Assert(err, NotNil)
+
+	c.Assert(s.pdnsCli.UpsertRecord("fake.zone.", "www.fake.zone", "A", 300, "192.0.2.1"), IsNil)
+	rrset, found, err := s.pdnsCli.GetRRset("fake.zone.", "www.fake.zone.", "A")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(rrset.Records, DeepEquals, shared.Records{{Content: "192.0.2.1"}})
+
+	zone, err = s.pdnsCli.GetZone("fake.zone.")
+	c.Assert(err, IsNil)
+	c.Assert(zone.Serial, Equals, uint32(2))
+	c.Assert(len(zone.RRsets), Equals, 3)
+
+	exists, err := s.pdnsCli.ZoneExists("fake.zone")
+	c.Assert(err, IsNil)
+	c.Assert(exists, Equals, true)
+
+	c.Assert(s.pdnsCli.DeleteZone("fake.zone."), IsNil)
+	_, err = s.pdnsCli.GetZone("fake.zone.")
+	c.Assert(powerdns.IsNotFound(err), Equals, true)
+}
+
+func (s *ServerSuite) TestCryptokeys(c *C) {
+	_, err := s.pdnsCli.CreateZone(authoritative.ZoneRequestNative{
+		Zone:        authoritative.Zone{Zone: shared.Zone{Name: "signed.zone."}, Kind: authoritative.KindNative},
+		Nameservers: []string{"ns1.signed.zone."},
+	})
+	c.Assert(err, IsNil)
+
+	// The zone has no keys, so is not signed.
+	c.Assert(s.pdnsCli.SetCryptoKeyActive("signed.zone.", 1, true), Equals, powerdns.ErrClientZoneNotDNSSEC)
+
+	key := authoritative.Cryptokey{}
+	err = s.pdnsCli.DoRequest("zones/signed.zone./cryptokeys", "POST", &authoritative.Cryptokey{Active: false}, &key)
+	c.Assert(err, IsNil)
+	c.Assert(key.ID, Equals, 1)
+
+	c.Assert(s.pdnsCli.SetCryptoKeyActive("signed.zone.", key.ID, true), IsNil)
+	keys := []authoritative.Cryptokey{}
+	c.Assert(s.pdnsCli.DoRequest("zones/signed.zone./cryptokeys", "GET", nil, &keys), IsNil)
+	c.Assert(len(keys), Equals, 1)
+	c.Assert(keys[0].Active, Equals, true)
+
+	c.Assert(s.pdnsCli.SetCryptoKeyActive("signed.zone.", 2, true), NotNil)
+}
+
+func (s *ServerSuite) TestSetRRsetComments(c *C) {
+	_, err := s.pdnsCli.CreateZone(authoritative.ZoneRequestNative{
+		Zone:        authoritative.Zone{Zone: shared.Zone{Name: "comments.zone."}, Kind: authoritative.KindNative},
+		Nameservers: []string{"ns1.comments.zone."},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(s.pdnsCli.UpsertRecord("comments.zone.", "www.comments.zone.", "A", 300, "192.0.2.1"), IsNil)
+
+	comments := []shared.Comment{{Content: "web server", Account: "ops"}}
+	c.Assert(s.pdnsCli.SetRRsetComments("comments.zone.", "www.comments.zone.", "A", comments), IsNil)
+
+	// The records are kept and only the comments replaced.
+	rrset, found, err := s.pdnsCli.GetRRset("comments.zone.", "www.comments.zone.", "A")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(rrset.Records, DeepEquals, shared.Records{{Content: "192.0.2.1"}})
+	c.Assert(rrset.Comments, DeepEquals, comments)
+
+	// Replacing the records keeps the comments.
+	c.Assert(s.pdnsCli.UpsertRecord("comments.zone.", "www.comments.zone.", "A", 300, "192.0.2.2"), IsNil)
+	rrset, _, err = s.pdnsCli.GetRRset("comments.zone.", "www.comments.zone.", "A")
+	c.Assert(err, IsNil)
+	c.Assert(rrset.Records, DeepEquals, shared.Records{{Content: "192.0.2.2"}})
+	c.Assert(rrset.Comments, DeepEquals, comments)
+}
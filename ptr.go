@@ -0,0 +1,70 @@
+package powerdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/wrouesnel/go.powerdns/internal/errs"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/records"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+// UpsertRecordWithPTR sets the A or AAAA RRset of the given name in the named zone to contain just ip, as for
+// UpsertRecord, and asks the server to create the matching PTR record. Since PowerDNS silently skips the PTR record
+// when there is no reverse zone for the address, ErrClientReverseZoneMissing is returned instead of making the change
+// in that case, wrapping the name of the PTR record.
+func (p *Client) UpsertRecordWithPTR(zone, name, rrtype string, ttl uint32, ip string) error {
+	return p.UpsertRecordWithPTRContext(context.Background(), zone, name, rrtype, ttl, ip)
+}
+
+// UpsertRecordWithPTRContext is like UpsertRecordWithPTR but uses ctx for the requests it makes.
+func (p *Client) UpsertRecordWithPTRContext(ctx context.Context,
+	zone, name, rrtype string, ttl uint32, ip string) error {
+	addr := net.ParseIP(ip)
+	switch {
+	case addr == nil:
+		return ErrClientPTRAddressInvalid
+	case strings.EqualFold(rrtype, "A") && (addr.To4() == nil || strings.Contains(ip, ":")):
+		return ErrClientPTRAddressInvalid
+	case strings.EqualFold(rrtype, "AAAA") && !strings.Contains(ip, ":"):
+		return ErrClientPTRAddressInvalid
+	case !strings.EqualFold(rrtype, "A") && !strings.EqualFold(rrtype, "AAAA"):
+		return ErrClientPTRAddressInvalid
+	}
+
+	ptrName, err := records.ReversePTRName(ip)
+	if err != nil {
+		return err
+	}
+	found, err := p.reverseZoneExists(ctx, ptrName)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errs.Wrap(ErrClientReverseZoneMissing, errors.New(ptrName))
+	}
+
+	rrset := shared.RRset{
+		Name:    canonicalName(name),
+		Type:    strings.ToUpper(rrtype),
+		TTL:     ttl,
+		Records: shared.Records{{Content: ip, SetPtr: true}},
+	}
+	return p.ReplaceRecordsContext(ctx, canonicalName(zone), shared.RRsets{rrset})
+}
+
+// reverseZoneExists returns whether a zone on the server contains ptrName. Its parent names are looked up in turn,
+// nearest first, as far as the in-addr.arpa. or ip6.arpa. zone, so only the zones which could hold it are fetched.
+func (p *Client) reverseZoneExists(ctx context.Context, ptrName string) (bool, error) {
+	candidate := ptrName
+	for strings.HasSuffix(candidate, ".arpa.") {
+		found, err := p.ZoneExistsContext(ctx, candidate)
+		if err != nil || found {
+			return found, err
+		}
+		candidate = candidate[strings.Index(candidate, ".")+1:]
+	}
+	return false, nil
+}
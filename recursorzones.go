@@ -0,0 +1,45 @@
+package powerdns
+
+import (
+	"context"
+
+	"github.com/wrouesnel/go.powerdns/internal/errs"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/recursor"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+// CreateForwardZone creates a recursor zone which forwards queries for name to servers, and returns the zone as
+// reported by the server. The name is normalized as for CreateZone.
+func (p *Client) CreateForwardZone(name string, servers []string, recursionDesired bool) (recursor.Zone, error) {
+	return p.CreateForwardZoneContext(context.Background(), name, servers, recursionDesired)
+}
+
+// CreateForwardZoneContext is like CreateForwardZone but uses ctx for the requests it makes.
+func (p *Client) CreateForwardZoneContext(ctx context.Context,
+	name string, servers []string, recursionDesired bool) (recursor.Zone, error) {
+	zoneResponse := recursor.Zone{}
+
+	zone := shared.Zone{Name: name}
+	zone.Normalize()
+	if err := zone.Validate(); err != nil {
+		return zoneResponse, errs.Wrap(ErrClientZoneNameInvalid, err)
+	}
+
+	if len(servers) == 0 {
+		return zoneResponse, ErrClientForwardServersEmpty
+	}
+
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeRecursor); err != nil {
+		return zoneResponse, err
+	}
+
+	req := recursor.Zone{
+		Zone:             zone,
+		Kind:             recursor.KindForwarded,
+		Servers:          servers,
+		RecursionDesired: recursionDesired,
+	}
+
+	err := p.DoRequestContext(ctx, zonesPathString, "POST", &req, &zoneResponse)
+	return zoneResponse, err
+}
@@ -0,0 +1,45 @@
+package powerdns
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+)
+
+const (
+	searchDataPathString = "search-data"
+)
+
+// Search searches zones, records and comments for query, which may contain '*' and '?' wildcards. At most max
+// results are returned. objectType restricts the results to one of "all", "zone", "record" or "comment"; an empty
+// objectType searches everything.
+func (p *Client) Search(query string, max int, objectType string) ([]authoritative.SearchResult, error) {
+	return p.SearchContext(context.Background(), query, max, objectType)
+}
+
+// SearchContext is like Search but uses ctx for the requests it makes.
+func (p *Client) SearchContext(ctx context.Context,
+	query string, max int, objectType string) ([]authoritative.SearchResult, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("max", strconv.Itoa(max))
+
+	switch authoritative.SearchObjectType(objectType) {
+	case "":
+	case authoritative.SearchObjectTypeAll, authoritative.SearchObjectTypeZone,
+		authoritative.SearchObjectTypeRecord, authoritative.SearchObjectTypeComment:
+		params.Set("object_type", objectType)
+	default:
+		return nil, ErrClientSearchTypeInvalid
+	}
+
+	results := []authoritative.SearchResult{}
+	if err := p.requireFeature(ctx, FeatureSearchData); err != nil {
+		return results, err
+	}
+
+	err := p.DoRequestContext(ctx, searchDataPathString+"?"+params.Encode(), "GET", nil, &results)
+	return results, err
+}
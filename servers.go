@@ -0,0 +1,128 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+const (
+	serversPathString = "servers"
+)
+
+// ListServers returns information on all servers the API exposes. In practice PowerDNS only ever reports one
+// server, "localhost".
+func (p *Client) ListServers() ([]shared.ServerInfo, error) {
+	return p.ListServersContext(context.Background())
+}
+
+// ListServersContext is like ListServers but uses ctx for the requests it makes.
+func (p *Client) ListServersContext(ctx context.Context) ([]shared.ServerInfo, error) {
+	servers := []shared.ServerInfo{}
+	err := p.doDecodedRequest(ctx, p.resolveAPIRequestPath, serversPathString, "GET", nil, &servers)
+	return servers, err
+}
+
+// ServerInfo returns information on the server the client is configured to use, including its daemon type and
+// version.
+func (p *Client) ServerInfo() (shared.ServerInfo, error) {
+	return p.ServerInfoContext(context.Background())
+}
+
+// ServerInfoContext is like ServerInfo but uses ctx for the requests it makes.
+func (p *Client) ServerInfoContext(ctx context.Context) (shared.ServerInfo, error) {
+	server := shared.ServerInfo{}
+	err := p.doDecodedRequest(ctx, p.resolveAPIRequestPath, p.serverInfoPath(), "GET", nil, &server)
+	return server, err
+}
+
+// Ping checks the server is up and accepting API requests, returning nil if it is. This is suitable as a readiness
+// check.
+func (p *Client) Ping(ctx context.Context) error {
+	return p.doDecodedRequest(ctx, p.resolveAPIRequestPath, p.serverInfoPath(), "GET", nil, nil)
+}
+
+// serverInfoPath returns the API sub-path of the configured server, relative to the API root.
+func (p *Client) serverInfoPath() string {
+	return fmt.Sprintf("%s/%s", serversPathString, url.PathEscape(p.server))
+}
+
+// serverInfoCache holds the info of a server once it has been looked up.
+type serverInfoCache struct {
+	mtx  sync.Mutex
+	info *shared.ServerInfo
+}
+
+// cachedServerInfo returns the server info, looking it up on first use. Failed lookups are not cached.
+func (p *Client) cachedServerInfo(ctx context.Context) (shared.ServerInfo, error) {
+	p.serverInfo.mtx.Lock()
+	defer p.serverInfo.mtx.Unlock()
+
+	if p.serverInfo.info != nil {
+		return *p.serverInfo.info, nil
+	}
+
+	server, err := p.ServerInfoContext(ctx)
+	if err != nil {
+		return shared.ServerInfo{}, err
+	}
+
+	p.serverInfo.info = &server
+	return server, nil
+}
+
+// DaemonType returns the daemon type of the server. The result is cached after the first successful lookup.
+func (p *Client) DaemonType() (shared.DaemonType, error) {
+	return p.DaemonTypeContext(context.Background())
+}
+
+// DaemonTypeContext is like DaemonType but uses ctx for the requests it makes.
+func (p *Client) DaemonTypeContext(ctx context.Context) (shared.DaemonType, error) {
+	server, err := p.cachedServerInfo(ctx)
+	if err != nil {
+		return "", err
+	}
+	return server.DaemonType, nil
+}
+
+// WithDaemonTypeCheck returns a copy of the client which verifies it is talking to the right type of server
+// before zone operations, returning ErrWrongDaemonType if it is not. Methods which need a newer server also check
+// its version, returning ErrUnsupportedFeature if it is too old.
+func (p *Client) WithDaemonTypeCheck() *Client {
+	r := *p
+	r.checkDaemonType = true
+	return &r
+}
+
+// ForServer returns a copy of the client which addresses the named server instead, sharing the same http.Client
+// and headers. The server is not contacted, so an unknown server is only reported by the first request.
+func (p *Client) ForServer(server string) *Client {
+	r := *p
+	r.server = server
+	// Built directly rather than parsed so that no server name can make the path invalid or absolute.
+	r.serverPath = &url.URL{Path: fmt.Sprintf("servers/%s/", server)}
+	// The new server may be of a different type and version.
+	r.serverInfo = &serverInfoCache{}
+	return &r
+}
+
+// requireDaemonType returns ErrWrongDaemonType if daemon type checking is enabled and the server is not of the
+// expected type.
+func (p *Client) requireDaemonType(ctx context.Context, expected shared.DaemonType) error {
+	if !p.checkDaemonType {
+		return nil
+	}
+
+	actual, err := p.DaemonTypeContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if actual != expected {
+		return ErrWrongDaemonType{Expected: expected, Actual: actual}
+	}
+	return nil
+}
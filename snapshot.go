@@ -0,0 +1,348 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/wrouesnel/go.powerdns/internal/errs"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+// protectedMetadataKinds are the metadata kinds PowerDNS does not allow to be changed through the API. They are
+// exported in snapshots but skipped on import; those which mirror zone fields, such as NSEC3PARAM, are restored
+// from the zone instead.
+var protectedMetadataKinds = map[string]bool{
+	"API-RECTIFY":      true,
+	"AXFR-MASTER-TSIG": true,
+	"LUA-AXFR-SCRIPT":  true,
+	"NSEC3NARROW":      true,
+	"NSEC3PARAM":       true,
+	"PRESIGNED":        true,
+	"SOA-EDIT-API":     true,
+	"TSIG-ALLOW-AXFR":  true,
+}
+
+// cryptokeysPath returns the API sub-path of the cryptokeys of the named zone.
+func cryptokeysPath(zone string) string {
+	return fmt.Sprintf("%s/cryptokeys", zonePath(zone))
+}
+
+// metadataPath returns the API sub-path of the metadata of the named zone, or of one kind of it if kind is not
+// empty.
+func metadataPath(zone, kind string) string {
+	if kind == "" {
+		return fmt.Sprintf("%s/metadata", zonePath(zone))
+	}
+	return fmt.Sprintf("%s/metadata/%s", zonePath(zone), url.PathEscape(kind))
+}
+
+// Snapshot holds the contents of a PowerDNS authoritative server as exported by ExportAll: every zone with its
+// RRsets, cryptokeys and metadata, and the server's TSIG keys. It is serializable as JSON, to back up a server or
+// copy its contents to another with ImportAll. Snapshots include private key material, so must be stored securely.
+type Snapshot struct {
+	Zones    []ZoneSnapshot          `json:"zones"`
+	TSIGKeys []authoritative.TSIGKey `json:"tsig_keys"`
+}
+
+// ZoneSnapshot holds one zone of a Snapshot.
+type ZoneSnapshot struct {
+	Zone       authoritative.ZoneResponse `json:"zone"`
+	Cryptokeys []authoritative.Cryptokey  `json:"cryptokeys"`
+	Metadata   []authoritative.Metadata   `json:"metadata"`
+}
+
+// ErrSnapshot is returned by ExportAll and ImportAll when some zones or TSIG keys failed, with the error for each
+// by name. Everything else was exported or imported, so the operation can be resumed by retrying just those which
+// failed.
+type ErrSnapshot struct {
+	Zones    map[string]error
+	TSIGKeys map[string]error
+}
+
+func (err ErrSnapshot) Error() string {
+	return fmt.Sprintf("Snapshot is incomplete: %d zones and %d TSIG keys failed", len(err.Zones), len(err.TSIGKeys))
+}
+
+// WrappedErrors implements errwrap.Wrapper. The zone errors come first, then those of the TSIG keys, each ordered
+// by name.
+func (err ErrSnapshot) WrappedErrors() []error {
+	ret := []error{}
+	for _, failed := range []map[string]error{err.Zones, err.TSIGKeys} {
+		names := make([]string, 0, len(failed))
+		for name := range failed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			ret = append(ret, failed[name])
+		}
+	}
+	return ret
+}
+
+// ExportAll exports every zone and TSIG key on the server into a Snapshot. A zone which cannot be exported does not
+// stop the export: the Snapshot holds the others, and an ErrSnapshot is returned with the error for each which
+// failed, which can be retried with ExportZoneSnapshot. Likewise if ctx is cancelled, the zones not yet exported are
+// reported as failed with the context's error. Other errors, such as failing to list the zones, are returned as-is.
+func (p *Client) ExportAll(ctx context.Context) (Snapshot, error) {
+	snapshot := Snapshot{Zones: []ZoneSnapshot{}, TSIGKeys: []authoritative.TSIGKey{}}
+	failed := ErrSnapshot{Zones: map[string]error{}, TSIGKeys: map[string]error{}}
+
+	zones, err := p.ListZonesContext(ctx)
+	if err != nil {
+		return snapshot, err
+	}
+
+	keys, err := p.ListTSIGKeysContext(ctx)
+	if err != nil && !IsNotFound(err) {
+		return snapshot, err
+	}
+	// Otherwise the server predates the TSIG key API, so has none.
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			failed.TSIGKeys[key.Name] = err
+			continue
+		}
+		// Only a request for a single key includes its key material.
+		full, err := p.GetTSIGKeyContext(ctx, key.ID)
+		if err != nil {
+			failed.TSIGKeys[key.Name] = err
+			continue
+		}
+		snapshot.TSIGKeys = append(snapshot.TSIGKeys, full)
+	}
+
+	for _, zone := range zones {
+		if err := ctx.Err(); err != nil {
+			failed.Zones[zone.Name] = err
+			continue
+		}
+		zoneSnapshot, err := p.ExportZoneSnapshot(ctx, zone.Name)
+		if err != nil {
+			failed.Zones[zone.Name] = err
+			continue
+		}
+		snapshot.Zones = append(snapshot.Zones, zoneSnapshot)
+	}
+
+	if len(failed.Zones) > 0 || len(failed.TSIGKeys) > 0 {
+		return snapshot, failed
+	}
+	return snapshot, nil
+}
+
+// ExportZoneSnapshot exports the named zone, with its RRsets, cryptokeys and metadata, as for ExportAll.
+func (p *Client) ExportZoneSnapshot(ctx context.Context, name string) (ZoneSnapshot, error) {
+	zoneSnapshot := ZoneSnapshot{Cryptokeys: []authoritative.Cryptokey{}, Metadata: []authoritative.Metadata{}}
+
+	zone, err := p.GetZoneContext(ctx, canonicalName(name))
+	if err != nil {
+		return zoneSnapshot, err
+	}
+	zoneSnapshot.Zone = zone
+
+	if zone.DNSsec {
+		keys := []authoritative.Cryptokey{}
+		if err := p.DoRequestContext(ctx, cryptokeysPath(zone.Name), "GET", nil, &keys); err != nil {
+			return zoneSnapshot, err
+		}
+		for _, key := range keys {
+			// Only a request for a single key includes its private key.
+			full := authoritative.Cryptokey{}
+			if err := p.DoRequestContext(ctx, cryptokeyPath(zone.Name, key.ID), "GET", nil, &full); err != nil {
+				return zoneSnapshot, err
+			}
+			zoneSnapshot.Cryptokeys = append(zoneSnapshot.Cryptokeys, full)
+		}
+	}
+
+	err = p.DoRequestContext(ctx, metadataPath(zone.Name, ""), "GET", nil, &zoneSnapshot.Metadata)
+	return zoneSnapshot, err
+}
+
+// ImportAll imports the zones and TSIG keys of snapshot into the server. Importing is idempotent, so an import
+// which was interrupted can be resumed by running it again:
+//
+// TSIG keys are created, or changed to match the snapshot if a key of the same name exists. They are imported
+// before the zones, whose metadata may refer to them.
+//
+// Zones are imported as for ImportZoneSnapshot.
+//
+// A zone or TSIG key which cannot be imported does not stop the import; an ErrSnapshot is returned with the error
+// for each which failed. Likewise if ctx is cancelled, those not yet imported are reported as failed with the
+// context's error.
+func (p *Client) ImportAll(ctx context.Context, snapshot Snapshot) error {
+	failed := ErrSnapshot{Zones: map[string]error{}, TSIGKeys: map[string]error{}}
+
+	if len(snapshot.TSIGKeys) > 0 {
+		existing, err := p.ListTSIGKeysContext(ctx)
+		if err != nil {
+			return err
+		}
+		ids := map[string]string{}
+		for _, key := range existing {
+			ids[strings.ToLower(canonicalName(key.Name))] = key.ID
+		}
+
+		for _, key := range snapshot.TSIGKeys {
+			if err := ctx.Err(); err != nil {
+				failed.TSIGKeys[key.Name] = err
+				continue
+			}
+
+			req := authoritative.TSIGKey{Name: key.Name, Algorithm: key.Algorithm, Key: key.Key}
+			if id, found := ids[strings.ToLower(canonicalName(key.Name))]; found {
+				_, err = p.ChangeTSIGKeyContext(ctx, id, req)
+			} else {
+				_, err = p.CreateTSIGKeyContext(ctx, req)
+			}
+			if err != nil {
+				failed.TSIGKeys[key.Name] = err
+			}
+		}
+	}
+
+	for _, zoneSnapshot := range snapshot.Zones {
+		if err := ctx.Err(); err != nil {
+			failed.Zones[zoneSnapshot.Zone.Name] = err
+			continue
+		}
+		if err := p.ImportZoneSnapshot(ctx, zoneSnapshot); err != nil {
+			failed.Zones[zoneSnapshot.Zone.Name] = err
+		}
+	}
+
+	if len(failed.Zones) > 0 || len(failed.TSIGKeys) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// ImportZoneSnapshot imports a zone exported by ExportZoneSnapshot, as for ImportAll. The zone is created if it
+// does not exist; otherwise its RRsets are made to match the snapshot, deleting any others. Slave zones are created
+// without RRsets, since they get their records by zone transfer. Cryptokeys are matched to existing ones by their
+// DNSKEY, and any missing are imported; existing keys which are not in the snapshot are left alone. Metadata is
+// replaced kind by kind, except for the kinds which PowerDNS does not allow to be changed through the API. Finally
+// the zone's header fields, including NSEC3 parameters and the masters of a slave zone, are set to match the
+// snapshot.
+func (p *Client) ImportZoneSnapshot(ctx context.Context, zoneSnapshot ZoneSnapshot) error {
+	zone := zoneSnapshot.Zone.Zone.Copy()
+	if zone.Kind == authoritative.KindSlave {
+		zone.RRsets = shared.RRsets{}
+	}
+
+	// Enabling DNSSEC when creating the zone would generate new keys, so it is set up by importing the keys, and
+	// NSEC3 once they exist.
+	header := zone.Copy()
+	header.DNSsec, header.NSEC3Param, header.NSEC3Narrow, header.Presigned = false, "", false, false
+
+	var err error
+	if zone.Kind == authoritative.KindSlave {
+		req := authoritative.ZoneRequestSlave{Zone: header, Masters: zoneSnapshot.Zone.Masters}
+		_, err = p.createZone(ctx, &req.Zone, &req)
+	} else {
+		req := authoritative.ZoneRequestMaster{Zone: header, Nameservers: []string{}}
+		_, err = p.createZone(ctx, &req.Zone, &req)
+	}
+	if statusCode, ok := StatusCode(err); ok && statusCode == http.StatusConflict {
+		err = p.reconcileZone(ctx, zone)
+	}
+	if err != nil {
+		return err
+	}
+	zone.Normalize()
+
+	if err := p.importCryptokeys(ctx, zone.Name, zoneSnapshot.Cryptokeys); err != nil {
+		return err
+	}
+
+	for _, metadata := range zoneSnapshot.Metadata {
+		if protectedMetadataKinds[strings.ToUpper(metadata.Kind)] {
+			continue
+		}
+		if err := p.DoRequestContext(ctx, metadataPath(zone.Name, metadata.Kind), "PUT", &metadata, nil); err != nil {
+			return err
+		}
+	}
+
+	update := authoritative.ZoneUpdate{
+		Kind:       &zone.Kind,
+		SoaEdit:    &zone.SoaEdit,
+		SoaEditAPI: &zone.SoaEditAPI,
+		Account:    &zone.Account,
+	}
+	if zone.NSEC3Param != "" {
+		update.NSEC3Param, update.NSEC3Narrow = &zone.NSEC3Param, &zone.NSEC3Narrow
+	}
+	if zone.Presigned {
+		update.Presigned = &zone.Presigned
+	}
+	if zone.Kind == authoritative.KindSlave {
+		update.Masters = &zoneSnapshot.Zone.Masters
+	}
+	return p.UpdateZoneContext(ctx, zone.Name, update)
+}
+
+// reconcileZone makes the RRsets of an existing zone match those of zone, unless it is a slave zone.
+func (p *Client) reconcileZone(ctx context.Context, zone authoritative.Zone) error {
+	if zone.Kind == authoritative.KindSlave {
+		return nil
+	}
+
+	zone.Normalize()
+	existing, err := p.GetZoneContext(ctx, zone.Name)
+	if err != nil {
+		return err
+	}
+
+	changes := authoritative.ReconcileRRsets(zone.RRsets, existing.RRsets)
+	if len(changes) == 0 {
+		return nil
+	}
+	return p.PatchZoneContext(ctx, zone.Name, changes)
+}
+
+// importCryptokeys imports the keys of the named zone which it does not already have, and sets whether existing
+// ones are active to match.
+func (p *Client) importCryptokeys(ctx context.Context, zone string, keys []authoritative.Cryptokey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	existing := []authoritative.Cryptokey{}
+	if err := p.DoRequestContext(ctx, cryptokeysPath(zone), "GET", nil, &existing); err != nil {
+		return err
+	}
+	existingByDNSKey := map[string]authoritative.Cryptokey{}
+	for _, key := range existing {
+		existingByDNSKey[key.DNSKey] = key
+	}
+
+	for _, key := range keys {
+		if current, found := existingByDNSKey[key.DNSKey]; found {
+			if current.Active != key.Active {
+				err := p.DoRequestContext(ctx, cryptokeyPath(zone, current.ID), "PUT",
+					&authoritative.Cryptokey{Active: key.Active}, nil)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if key.PrivateKey == "" {
+			return errs.Wrap(ErrClientCryptokeyNoPrivateKey, fmt.Errorf("cryptokey %d", key.ID))
+		}
+		req := authoritative.Cryptokey{KeyType: key.KeyType, Active: key.Active, PrivateKey: key.PrivateKey}
+		if err := p.DoRequestContext(ctx, cryptokeysPath(zone), "POST", &req, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package powerdns
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// Tracer starts spans for a distributed tracing system such as OpenTelemetry. StartSpan returns a context carrying
+// the new span, which is used for the request so transports can propagate it.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced request. The Client sets the attributes "http.method", "http.url", "http.status_code"
+// (once a response is received) and "powerdns.zone" (for requests about a zone), and ends the span with the
+// request's error, if any.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End(err error)
+}
+
+// zoneFromSubPath returns the ID of the zone a request sub-path addresses, or "" if it is not about a zone.
+func zoneFromSubPath(subPath *url.URL) string {
+	segments := strings.Split(strings.Trim(subPath.Path, "/"), "/")
+	if segments[0] == zonesPathString && len(segments) > 1 {
+		return segments[1]
+	}
+	return ""
+}
@@ -0,0 +1,24 @@
+package powerdns
+
+import (
+	"context"
+
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+const (
+	statisticsPathString = "statistics"
+)
+
+// Statistics returns the server's statistics. Each item reports either a single value or, for map and ring
+// statistics, a list of named values. This works against either daemon type.
+func (p *Client) Statistics() ([]shared.StatisticItem, error) {
+	return p.StatisticsContext(context.Background())
+}
+
+// StatisticsContext is like Statistics but uses ctx for the requests it makes.
+func (p *Client) StatisticsContext(ctx context.Context) ([]shared.StatisticItem, error) {
+	stats := []shared.StatisticItem{}
+	err := p.DoRequestContext(ctx, statisticsPathString, "GET", nil, &stats)
+	return stats, err
+}
@@ -9,52 +9,12 @@ import (
 	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
 )
 
-// Temporary list of dnstypes
-var dnsTypes []string = []string{
-	"A",
-	"AAAA",
-	"AFSDB",
-	"APL",
-	"CAA",
-	"CDNSKEY",
-	"CDS",
-	"CERT",
-	"CNAME",
-	"DHCID",
-	"DLV",
-	"DNAME",
-	"DNSKEY",
-	"DS",
-	"HIP",
-	"IPSECKEY",
-	"KEY",
-	"KX",
-	"LOC",
-	"MX",
-	"NAPTR",
-	"NS",
-	"NSEC",
-	"NSEC3",
-	"NSEC3PARAM",
-	"OPENPGPKEY",
-	"PTR",
-	"RRSIG",
-	"RP",
-	"SIG",
-	"SOA",
-	"SRV",
-	"SSHFP",
-	"TA",
-	"TKEY",
-	"TLSA",
-	"TSIG",
-	"TXT",
-	"URI",
-}
+// dnsTypes is the list of types MakeRRsets picks from.
+var dnsTypes = shared.RRTypes()
 
 // DnsTypes returns a list of DnsTypes as a string slice copy
 func DnsTypes() []string {
-	return dnsTypes[:]
+	return shared.RRTypes()
 }
 
 // MakeMixedHostIPList makes a list of random hostnames and IPs as a string slice
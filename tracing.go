@@ -0,0 +1,62 @@
+package powerdns
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+)
+
+// redactedHeaders lists the headers whose values are never written to a trace, since they carry credentials.
+var redactedHeaders = map[string]bool{
+	"X-Api-Key":     true,
+	"Authorization": true,
+}
+
+// traceHeaders writes headers to w in sorted order, each line prefixed by prefix, with credentials redacted. The
+// value of secretHeader is also redacted, if it is set.
+func traceHeaders(w io.Writer, prefix string, headers http.Header, secretHeader string) {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range headers[key] {
+			canonicalKey := http.CanonicalHeaderKey(key)
+			if redactedHeaders[canonicalKey] || canonicalKey == http.CanonicalHeaderKey(secretHeader) {
+				value = "[REDACTED]"
+			}
+			fmt.Fprintf(w, "%s %s: %s\n", prefix, key, value) // nolint: errcheck
+		}
+	}
+}
+
+// traceRequest writes the request line, headers and body of req to w. secretHeader is redacted as for traceHeaders.
+func traceRequest(w io.Writer, req *http.Request, body []byte, secretHeader string) {
+	fmt.Fprintf(w, "> %s %s\n", req.Method, req.URL) // nolint: errcheck
+	traceHeaders(w, ">", req.Header, secretHeader)
+	fmt.Fprintf(w, "\n%s\n", body) // nolint: errcheck
+}
+
+// traceResponse writes the status line and headers of resp to w, and arranges for the body to be copied to w as
+// it is read.
+func traceResponse(w io.Writer, resp *http.Response) {
+	fmt.Fprintf(w, "< %s\n", resp.Status) // nolint: errcheck
+	traceHeaders(w, "<", resp.Header, "")
+	fmt.Fprint(w, "\n") // nolint: errcheck
+	resp.Body = tracedBody{io.TeeReader(resp.Body, w), resp.Body, w}
+}
+
+// tracedBody copies a response body to a trace as it is read.
+type tracedBody struct {
+	io.Reader
+	body  io.Closer
+	trace io.Writer
+}
+
+func (b tracedBody) Close() error {
+	fmt.Fprint(b.trace, "\n") // nolint: errcheck
+	return b.body.Close()
+}
@@ -0,0 +1,79 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+)
+
+const (
+	tsigKeysPathString = "tsigkeys"
+)
+
+// tsigKeyPath returns the API sub-path of the TSIG key with the given server-assigned ID.
+func tsigKeyPath(id string) string {
+	return fmt.Sprintf("%s/%s", tsigKeysPathString, url.PathEscape(id))
+}
+
+// ListTSIGKeys returns all TSIG keys on the server. The key material is not included.
+func (p *Client) ListTSIGKeys() ([]authoritative.TSIGKey, error) {
+	return p.ListTSIGKeysContext(context.Background())
+}
+
+// ListTSIGKeysContext is like ListTSIGKeys but uses ctx for the requests it makes.
+func (p *Client) ListTSIGKeysContext(ctx context.Context) ([]authoritative.TSIGKey, error) {
+	keys := []authoritative.TSIGKey{}
+	err := p.DoRequestContext(ctx, tsigKeysPathString, "GET", nil, &keys)
+	return keys, err
+}
+
+// GetTSIGKey returns the TSIG key with the given ID, including its key material.
+func (p *Client) GetTSIGKey(id string) (authoritative.TSIGKey, error) {
+	return p.GetTSIGKeyContext(context.Background(), id)
+}
+
+// GetTSIGKeyContext is like GetTSIGKey but uses ctx for the requests it makes.
+func (p *Client) GetTSIGKeyContext(ctx context.Context, id string) (authoritative.TSIGKey, error) {
+	key := authoritative.TSIGKey{}
+	err := p.DoRequestContext(ctx, tsigKeyPath(id), "GET", nil, &key)
+	return key, err
+}
+
+// CreateTSIGKey creates a new TSIG key. If req.Key is empty the server generates the key material, otherwise the
+// supplied base64 key is imported. The created key is returned.
+func (p *Client) CreateTSIGKey(req authoritative.TSIGKey) (authoritative.TSIGKey, error) {
+	return p.CreateTSIGKeyContext(context.Background(), req)
+}
+
+// CreateTSIGKeyContext is like CreateTSIGKey but uses ctx for the requests it makes.
+func (p *Client) CreateTSIGKeyContext(ctx context.Context, req authoritative.TSIGKey) (authoritative.TSIGKey, error) {
+	key := authoritative.TSIGKey{}
+	err := p.DoRequestContext(ctx, tsigKeysPathString, "POST", &req, &key)
+	return key, err
+}
+
+// ChangeTSIGKey updates the name, algorithm or key material of the TSIG key with the given ID. The updated key is
+// returned.
+func (p *Client) ChangeTSIGKey(id string, req authoritative.TSIGKey) (authoritative.TSIGKey, error) {
+	return p.ChangeTSIGKeyContext(context.Background(), id, req)
+}
+
+// ChangeTSIGKeyContext is like ChangeTSIGKey but uses ctx for the requests it makes.
+func (p *Client) ChangeTSIGKeyContext(ctx context.Context,
+	id string, req authoritative.TSIGKey) (authoritative.TSIGKey, error) {
+	key := authoritative.TSIGKey{}
+	err := p.DoRequestContext(ctx, tsigKeyPath(id), "PUT", &req, &key)
+	return key, err
+}
+
+// DeleteTSIGKey removes the TSIG key with the given ID.
+func (p *Client) DeleteTSIGKey(id string) error {
+	return p.DeleteTSIGKeyContext(context.Background(), id)
+}
+
+// DeleteTSIGKeyContext is like DeleteTSIGKey but uses ctx for the requests it makes.
+func (p *Client) DeleteTSIGKeyContext(ctx context.Context, id string) error {
+	return p.DoRequestContext(ctx, tsigKeyPath(id), "DELETE", nil, nil)
+}
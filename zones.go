@@ -0,0 +1,721 @@
+package powerdns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wrouesnel/go.powerdns/internal/errs"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/authoritative"
+	"github.com/wrouesnel/go.powerdns/pdnstypes/shared"
+)
+
+const (
+	zonesPathString = "zones"
+)
+
+// zoneID converts a zone name to the zone ID PowerDNS uses to address it in URLs. This mirrors the server's own
+// encoding: anything other than letters, digits, '.' and '-' is escaped as "=XX", which leaves a string that is
+// safe to place in a URL path (e.g. "0/26.2.0.192.in-addr.arpa." becomes "0=2F26.2.0.192.in-addr.arpa.").
+func zoneID(name string) string {
+	if name == "." {
+		return "=2E"
+	}
+
+	id := make([]byte, 0, len(name))
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9', ch == '.', ch == '-':
+			id = append(id, ch)
+		default:
+			id = append(id, []byte(fmt.Sprintf("=%02X", ch))...)
+		}
+	}
+	return string(id)
+}
+
+// zonePath returns the API sub-path of the named zone.
+func zonePath(name string) string {
+	return fmt.Sprintf("%s/%s", zonesPathString, zoneID(name))
+}
+
+// createZone normalizes and validates the zone header and POSTs the given request body to the zones endpoint. zone
+// must point into req so the normalized name is sent.
+func (p *Client) createZone(ctx context.Context,
+	zone *authoritative.Zone, req interface{}) (authoritative.ZoneResponse, error) {
+	zoneResponse := authoritative.ZoneResponse{}
+
+	if p.autoFQDN {
+		zone.RRsets = fqdnRRsets(zone.RRsets)
+	}
+	zone.Normalize()
+	if err := zone.Validate(); err != nil {
+		return zoneResponse, errs.Wrap(ErrClientZoneNameInvalid, err)
+	}
+
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeAuthoritative); err != nil {
+		return zoneResponse, err
+	}
+
+	err := p.DoRequestContext(ctx, zonesPathString, "POST", req, &zoneResponse)
+	return zoneResponse, err
+}
+
+// checkNameservers returns ErrClientNameserversWithNS if nameservers are given for a zone which also has an NS
+// RRset at its apex, which the server would reject. The zone name must already be normalized.
+func checkNameservers(zone *authoritative.Zone, nameservers []string) error {
+	if len(nameservers) == 0 {
+		return nil
+	}
+	for _, rrset := range zone.RRsets {
+		if strings.EqualFold(rrset.Type, "NS") && strings.EqualFold(canonicalName(rrset.Name), zone.Name) {
+			return ErrClientNameserversWithNS
+		}
+	}
+	return nil
+}
+
+// CreateZone creates a new native zone and returns the zone as reported by the server. Either req.Nameservers or
+// an NS RRset at the zone apex in req.RRsets may be given, but not both; RRsets are sent as-is.
+func (p *Client) CreateZone(req authoritative.ZoneRequestNative) (authoritative.ZoneResponse, error) {
+	return p.CreateZoneContext(context.Background(), req)
+}
+
+// CreateZoneContext is like CreateZone but uses ctx for the requests it makes.
+func (p *Client) CreateZoneContext(ctx context.Context,
+	req authoritative.ZoneRequestNative) (authoritative.ZoneResponse, error) {
+	if p.autoFQDN {
+		req.Nameservers = fqdnNames(req.Nameservers)
+	}
+	req.Zone.Normalize()
+	if err := checkNameservers(&req.Zone, req.Nameservers); err != nil {
+		return authoritative.ZoneResponse{}, err
+	}
+	return p.createZone(ctx, &req.Zone, &req)
+}
+
+// CreateZoneWithRRsets creates a new native zone containing rrsets, which should include the NS RRset of the zone
+// since no nameservers are given, and returns the zone as reported by the server.
+func (p *Client) CreateZoneWithRRsets(name string, rrsets shared.RRsets) (authoritative.ZoneResponse, error) {
+	return p.CreateZoneWithRRsetsContext(context.Background(), name, rrsets)
+}
+
+// CreateZoneWithRRsetsContext is like CreateZoneWithRRsets but uses ctx for the requests it makes.
+func (p *Client) CreateZoneWithRRsetsContext(ctx context.Context,
+	name string, rrsets shared.RRsets) (authoritative.ZoneResponse, error) {
+	return p.CreateZoneContext(ctx, authoritative.ZoneRequestNative{
+		Zone: authoritative.Zone{
+			Zone: shared.Zone{Name: name, RRsets: rrsets},
+			Kind: authoritative.KindNative,
+		},
+		Nameservers: []string{},
+	})
+}
+
+// CreateMasterZone creates a new master zone and returns the zone as reported by the server. Nameservers are as
+// for CreateZone.
+func (p *Client) CreateMasterZone(req authoritative.ZoneRequestMaster) (authoritative.ZoneResponse, error) {
+	return p.CreateMasterZoneContext(context.Background(), req)
+}
+
+// CreateMasterZoneContext is like CreateMasterZone but uses ctx for the requests it makes.
+func (p *Client) CreateMasterZoneContext(ctx context.Context,
+	req authoritative.ZoneRequestMaster) (authoritative.ZoneResponse, error) {
+	if p.autoFQDN {
+		req.Nameservers = fqdnNames(req.Nameservers)
+	}
+	req.Zone.Normalize()
+	if err := checkNameservers(&req.Zone, req.Nameservers); err != nil {
+		return authoritative.ZoneResponse{}, err
+	}
+	return p.createZone(ctx, &req.Zone, &req)
+}
+
+// CreateSlaveZone creates a new slave zone and returns the zone as reported by the server.
+func (p *Client) CreateSlaveZone(req authoritative.ZoneRequestSlave) (authoritative.ZoneResponse, error) {
+	return p.CreateSlaveZoneContext(context.Background(), req)
+}
+
+// CreateSlaveZoneContext is like CreateSlaveZone but uses ctx for the requests it makes.
+func (p *Client) CreateSlaveZoneContext(ctx context.Context,
+	req authoritative.ZoneRequestSlave) (authoritative.ZoneResponse, error) {
+	return p.createZone(ctx, &req.Zone, &req)
+}
+
+// CreateOrUpdateZone creates the zone described by req, or if it already exists (the server responds 409 Conflict)
+// replaces any of its RRsets which differ from those in req. RRsets in the existing zone which are not in req are
+// left alone, so the SOA and NS records the server generates are preserved. The zone is returned as reported by the
+// server after the update.
+func (p *Client) CreateOrUpdateZone(req authoritative.ZoneRequestNative) (authoritative.ZoneResponse, error) {
+	return p.CreateOrUpdateZoneContext(context.Background(), req)
+}
+
+// CreateOrUpdateZoneContext is like CreateOrUpdateZone but uses ctx for the requests it makes.
+func (p *Client) CreateOrUpdateZoneContext(ctx context.Context,
+	req authoritative.ZoneRequestNative) (authoritative.ZoneResponse, error) {
+	zoneResponse, err := p.CreateZoneContext(ctx, req)
+	if statusCode, ok := StatusCode(err); !ok || statusCode != http.StatusConflict {
+		return zoneResponse, err
+	}
+
+	existing, err := p.GetZoneContext(ctx, req.Name)
+	if err != nil {
+		return existing, err
+	}
+
+	actual := existing.RRsets.ToMap()
+	changed := shared.RRsets{}
+	for _, rrset := range req.RRsets {
+		if current, found := actual[rrset.UniqueName()]; !found || !current.Equals(rrset) {
+			changed = append(changed, rrset)
+		}
+	}
+
+	if len(changed) == 0 {
+		return existing, nil
+	}
+
+	if err := p.ReplaceRecordsContext(ctx, req.Name, changed); err != nil {
+		return existing, err
+	}
+
+	return p.GetZoneContext(ctx, req.Name)
+}
+
+// ListZones returns all zones on the server.
+func (p *Client) ListZones() ([]authoritative.ZoneResponse, error) {
+	return p.ListZonesContext(context.Background())
+}
+
+// ListZonesContext is like ListZones but uses ctx for the requests it makes.
+func (p *Client) ListZonesContext(ctx context.Context) ([]authoritative.ZoneResponse, error) {
+	zoneList := []authoritative.ZoneResponse{}
+
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeAuthoritative); err != nil {
+		return zoneList, err
+	}
+
+	err := p.DoRequestContext(ctx, zonesPathString, "GET", nil, &zoneList)
+	return zoneList, err
+}
+
+// IterZones lists the zones on the server, decoding them one at a time as they are read from the response rather
+// than buffering the whole list. Each call to the returned function returns the next zone, or io.EOF once all zones
+// have been returned. The response is closed when io.EOF or an error is returned; a caller which stops early should
+// cancel ctx to release it.
+func (p *Client) IterZones(ctx context.Context) (func() (authoritative.ZoneResponse, error), error) {
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeAuthoritative); err != nil {
+		return nil, err
+	}
+
+	body, _, err := p.openRequest(ctx, p.resolveRequestPath, zonesPathString, "GET", "application/json", true, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(body)
+	if token, terr := decoder.Token(); terr != nil || token != json.Delim('[') {
+		body.Close() //nolint: errcheck
+		if terr == nil {
+			terr = fmt.Errorf("expected start of zone list, got %v", token)
+		}
+		return nil, errs.Wrap(ErrClientServerResponseUnreadable{}, terr)
+	}
+
+	var iterErr error
+	next := func() (authoritative.ZoneResponse, error) {
+		zoneResponse := authoritative.ZoneResponse{}
+		if iterErr != nil {
+			return zoneResponse, iterErr
+		}
+
+		if !decoder.More() {
+			iterErr = io.EOF
+		} else if derr := decoder.Decode(&zoneResponse); derr != nil {
+			iterErr = errs.Wrap(ErrClientServerResponseUnreadable{}, derr)
+		} else {
+			return zoneResponse, nil
+		}
+
+		body.Close() //nolint: errcheck
+		return authoritative.ZoneResponse{}, iterErr
+	}
+
+	return next, nil
+}
+
+// ListZonesOptions controls the zones returned by ListZonesFiltered.
+type ListZonesOptions struct {
+	// NameFilter restricts the result to the zone with this name, if set.
+	NameFilter string
+	// IncludeRRsets requests the RRsets of each zone. When false (the default) the RRsets of the returned zones are
+	// nil, which greatly reduces the response size on servers with many zones. Servers before 4.2 ignore the
+	// parameter, so any RRsets they return are discarded.
+	IncludeRRsets bool
+}
+
+// ListZonesFiltered returns the zones on the server matching opts.
+func (p *Client) ListZonesFiltered(opts ListZonesOptions) ([]authoritative.ZoneResponse, error) {
+	return p.ListZonesFilteredContext(context.Background(), opts)
+}
+
+// ListZonesFilteredContext is like ListZonesFiltered but uses ctx for the requests it makes.
+func (p *Client) ListZonesFilteredContext(ctx context.Context,
+	opts ListZonesOptions) ([]authoritative.ZoneResponse, error) {
+	zoneList := []authoritative.ZoneResponse{}
+
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeAuthoritative); err != nil {
+		return zoneList, err
+	}
+
+	query := url.Values{}
+	if opts.NameFilter != "" {
+		query.Set("zone", opts.NameFilter)
+	}
+	query.Set("rrsets", strconv.FormatBool(opts.IncludeRRsets))
+
+	if err := p.DoRequestContext(ctx, zonesPathString+"?"+query.Encode(), "GET", nil, &zoneList); err != nil {
+		return zoneList, err
+	}
+
+	if !opts.IncludeRRsets {
+		for i := range zoneList {
+			zoneList[i].RRsets = nil
+		}
+	}
+	return zoneList, nil
+}
+
+// ZoneExists returns whether the named zone exists on the server. Only the zone header is fetched, not its records.
+func (p *Client) ZoneExists(name string) (bool, error) {
+	return p.ZoneExistsContext(context.Background(), name)
+}
+
+// ZoneExistsContext is like ZoneExists but uses ctx for the requests it makes.
+func (p *Client) ZoneExistsContext(ctx context.Context, name string) (bool, error) {
+	name = canonicalName(name)
+	zones, err := p.ListZonesFilteredContext(ctx, ListZonesOptions{NameFilter: name})
+	if err != nil {
+		return false, err
+	}
+
+	for _, zone := range zones {
+		if strings.EqualFold(zone.Name, name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetZone returns the named zone including its RRsets. If the zone does not exist, the returned error
+// wraps ErrNotFound.
+func (p *Client) GetZone(name string) (authoritative.ZoneResponse, error) {
+	return p.GetZoneContext(context.Background(), name)
+}
+
+// GetZoneContext is like GetZone but uses ctx for the requests it makes.
+func (p *Client) GetZoneContext(ctx context.Context, name string) (authoritative.ZoneResponse, error) {
+	zoneResponse := authoritative.ZoneResponse{}
+
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeAuthoritative); err != nil {
+		return zoneResponse, err
+	}
+
+	err := p.DoRequestContext(ctx, zonePath(name), "GET", nil, &zoneResponse)
+	return zoneResponse, err
+}
+
+// GetRRset returns the RRset of the given name and type in the named zone, and whether it was found. zone and name
+// need not be fully qualified. The server is asked for only the matching RRset, which PowerDNS 4.8 and later
+// support; older servers return the whole zone and the RRset is found locally.
+func (p *Client) GetRRset(zone, name, rrtype string) (shared.RRset, bool, error) {
+	return p.GetRRsetContext(context.Background(), zone, name, rrtype)
+}
+
+// GetRRsetContext is like GetRRset but uses ctx for the requests it makes.
+func (p *Client) GetRRsetContext(ctx context.Context, zone, name, rrtype string) (shared.RRset, bool, error) {
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeAuthoritative); err != nil {
+		return shared.RRset{}, false, err
+	}
+	zone, name = canonicalName(zone), canonicalName(name)
+
+	query := url.Values{}
+	query.Set("rrset_name", name)
+	query.Set("rrset_type", rrtype)
+
+	zoneResponse := authoritative.ZoneResponse{}
+	if err := p.DoRequestContext(ctx, zonePath(zone)+"?"+query.Encode(), "GET", nil, &zoneResponse); err != nil {
+		return shared.RRset{}, false, err
+	}
+
+	for _, rrset := range zoneResponse.RRsets {
+		if strings.EqualFold(rrset.Name, name) && strings.EqualFold(rrset.Type, rrtype) {
+			return rrset, true, nil
+		}
+	}
+	return shared.RRset{}, false, nil
+}
+
+// DeleteZone removes the named zone and all its records from the server. If the zone does not exist, the
+// returned error wraps ErrNotFound.
+func (p *Client) DeleteZone(name string) error {
+	return p.DeleteZoneContext(context.Background(), name)
+}
+
+// DeleteZoneContext is like DeleteZone but uses ctx for the requests it makes.
+func (p *Client) DeleteZoneContext(ctx context.Context, name string) error {
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeAuthoritative); err != nil {
+		return err
+	}
+
+	return p.DoRequestContext(ctx, zonePath(name), "DELETE", nil, nil)
+}
+
+// SetZoneKind changes the kind of the named zone, e.g. to promote a slave to master during a failover. The zone's
+// records are kept.
+func (p *Client) SetZoneKind(name string, kind authoritative.Kind) error {
+	return p.SetZoneKindContext(context.Background(), name, kind)
+}
+
+// SetZoneKindContext is like SetZoneKind but uses ctx for the requests it makes.
+func (p *Client) SetZoneKindContext(ctx context.Context, name string, kind authoritative.Kind) error {
+	if !kind.IsValid() {
+		return ErrClientZoneKindInvalid
+	}
+
+	return p.UpdateZoneContext(ctx, name, authoritative.ZoneUpdate{Kind: &kind})
+}
+
+// UpdateZone changes the header fields of the named zone set in update. The zone's records are not affected.
+func (p *Client) UpdateZone(name string, update authoritative.ZoneUpdate) error {
+	return p.UpdateZoneContext(context.Background(), name, update)
+}
+
+// UpdateZoneContext is like UpdateZone but uses ctx for the requests it makes.
+func (p *Client) UpdateZoneContext(ctx context.Context, name string, update authoritative.ZoneUpdate) error {
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeAuthoritative); err != nil {
+		return err
+	}
+
+	return p.DoRequestContext(ctx, zonePath(name), "PUT", &update, nil)
+}
+
+// SetNSEC3Param sets the NSEC3PARAM of the named zone, e.g. "1 0 1 ab", switching it from NSEC to NSEC3. An empty
+// param switches the zone back to NSEC. narrow enables NSEC3 narrow mode. The zone must have DNSSEC enabled.
+func (p *Client) SetNSEC3Param(zone, param string, narrow bool) error {
+	return p.SetNSEC3ParamContext(context.Background(), zone, param, narrow)
+}
+
+// SetNSEC3ParamContext is like SetNSEC3Param but uses ctx for the requests it makes.
+func (p *Client) SetNSEC3ParamContext(ctx context.Context, zone, param string, narrow bool) error {
+	return p.UpdateZoneContext(ctx, zone, authoritative.ZoneUpdate{NSEC3Param: &param, NSEC3Narrow: &narrow})
+}
+
+// UpdateZoneMetadata changes the settings of the named zone set in update, such as its SOA-EDIT, account or DNSSEC
+// flag. It is equivalent to UpdateZone; the zone's records are not affected.
+func (p *Client) UpdateZoneMetadata(name string, update authoritative.ZoneUpdate) error {
+	return p.UpdateZoneMetadataContext(context.Background(), name, update)
+}
+
+// UpdateZoneMetadataContext is like UpdateZoneMetadata but uses ctx for the requests it makes.
+func (p *Client) UpdateZoneMetadataContext(ctx context.Context, name string, update authoritative.ZoneUpdate) error {
+	return p.UpdateZoneContext(ctx, name, update)
+}
+
+// ExportZone returns the named zone as an RFC1035 (BIND-style) zonefile.
+func (p *Client) ExportZone(name string) (string, error) {
+	return p.ExportZoneContext(context.Background(), name)
+}
+
+// ExportZoneContext is like ExportZone but uses ctx for the requests it makes.
+func (p *Client) ExportZoneContext(ctx context.Context, name string) (string, error) {
+	zoneFile, _, err := p.doRequest(ctx, p.resolveRequestPath, zonePath(name)+"/export", "GET",
+		"text/plain", false, nil)
+	return string(zoneFile), err
+}
+
+// NotifyZone sends a DNS NOTIFY for the named zone to its slaves. ErrClientZoneNotMaster is returned if the zone
+// is a slave zone, since there is nothing to notify.
+func (p *Client) NotifyZone(name string) error {
+	return p.NotifyZoneContext(context.Background(), name)
+}
+
+// NotifyZoneContext is like NotifyZone but uses ctx for the requests it makes.
+func (p *Client) NotifyZoneContext(ctx context.Context, name string) error {
+	zoneResponse, err := p.GetZoneContext(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if zoneResponse.Kind != authoritative.KindMaster && zoneResponse.Kind != authoritative.KindNative {
+		return ErrClientZoneNotMaster
+	}
+
+	return p.DoRequestContext(ctx, zonePath(name)+"/notify", "PUT", nil, nil)
+}
+
+// RectifyZone rectifies the named zone, recomputing its DNSSEC ordering and NSEC records, and returns the result
+// reported by the server. This is needed after changing records in a zone without API-RECTIFY enabled.
+func (p *Client) RectifyZone(name string) (string, error) {
+	return p.RectifyZoneContext(context.Background(), name)
+}
+
+// RectifyZoneContext is like RectifyZone but uses ctx for the requests it makes.
+func (p *Client) RectifyZoneContext(ctx context.Context, name string) (string, error) {
+	result := shared.ActionResult{}
+	if err := p.requireFeature(ctx, FeatureRectify); err != nil {
+		return "", err
+	}
+
+	err := p.DoRequestContext(ctx, zonePath(name)+"/rectify", "PUT", nil, &result)
+	return result.Result, err
+}
+
+// RetrieveZone asks the server to retrieve the named slave zone from its master by AXFR, and returns the result
+// reported by the server. The transfer completes in the background; use WaitForSerial to wait for it.
+func (p *Client) RetrieveZone(name string) (string, error) {
+	return p.RetrieveZoneContext(context.Background(), name)
+}
+
+// RetrieveZoneContext is like RetrieveZone but uses ctx for the requests it makes.
+func (p *Client) RetrieveZoneContext(ctx context.Context, name string) (string, error) {
+	result := shared.ActionResult{}
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeAuthoritative); err != nil {
+		return "", err
+	}
+
+	err := p.DoRequestContext(ctx, zonePath(name)+"/axfr-retrieve", "PUT", nil, &result)
+	return result.Result, err
+}
+
+// WaitForSerial polls the named zone every poll interval until its serial is at least minSerial, and returns the
+// serial. If ctx expires first, the last serial seen is returned with an error wrapping ErrClientSerialWait. Errors
+// fetching the zone are returned immediately.
+func (p *Client) WaitForSerial(ctx context.Context, zone string, minSerial uint32,
+	poll time.Duration) (uint32, error) {
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	var serial uint32
+	for {
+		zoneResponse, err := p.GetZoneContext(ctx, zone)
+		if err != nil {
+			// The request failing because ctx expired is reported as the wait ending.
+			if ctx.Err() != nil {
+				return serial, errs.Wrap(ErrClientSerialWait, ctx.Err())
+			}
+			return serial, err
+		}
+
+		serial = zoneResponse.Serial
+		if serial >= minSerial {
+			return serial, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return serial, errs.Wrap(ErrClientSerialWait, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// PatchZone applies the given RRset changes to the named zone.
+func (p *Client) PatchZone(name string, rrsets authoritative.PatchRRSets) error {
+	return p.PatchZoneContext(context.Background(), name, rrsets)
+}
+
+// PatchZoneContext is like PatchZone but uses ctx for the requests it makes.
+func (p *Client) PatchZoneContext(ctx context.Context, name string, rrsets authoritative.PatchRRSets) error {
+	if err := p.requireDaemonType(ctx, shared.DaemonTypeAuthoritative); err != nil {
+		return err
+	}
+
+	if p.autoFQDN {
+		name = canonicalName(name)
+		rrsets = fqdnPatchRRSets(rrsets)
+	}
+
+	patchRequest := authoritative.PatchZoneRequest{RRSets: rrsets}
+	return p.DoRequestContext(ctx, zonePath(name), "PATCH", &patchRequest, nil)
+}
+
+// ErrPatchBatch is returned by PatchZoneBatched when a batch of changes fails. The first Applied changes were
+// applied by earlier batches; none of the failed batch or later ones were.
+type ErrPatchBatch struct {
+	Applied int
+	Err     error
+}
+
+func (err ErrPatchBatch) Error() string {
+	return fmt.Sprintf("Zone patch failed after %d changes were applied: %v", err.Applied, err.Err)
+}
+
+// WrappedErrors implements errwrap.Wrapper
+func (err ErrPatchBatch) WrappedErrors() []error {
+	return []error{err.Err}
+}
+
+// Unwrap returns Err, for errors.Is and errors.As.
+func (err ErrPatchBatch) Unwrap() error {
+	return err.Err
+}
+
+// PatchZoneBatched applies the given RRset changes to the named zone as for PatchZone, but in batches of at most
+// maxPerBatch changes, to keep requests under the size limits of the server or a proxy. Batches are applied in
+// order, stopping at the first which fails with an ErrPatchBatch. Each batch is atomic but the whole is not. If
+// maxPerBatch is not positive all the changes are sent in one batch.
+func (p *Client) PatchZoneBatched(name string, rrsets authoritative.PatchRRSets, maxPerBatch int) error {
+	return p.PatchZoneBatchedContext(context.Background(), name, rrsets, maxPerBatch)
+}
+
+// PatchZoneBatchedContext is like PatchZoneBatched but uses ctx for the requests it makes.
+func (p *Client) PatchZoneBatchedContext(ctx context.Context,
+	name string, rrsets authoritative.PatchRRSets, maxPerBatch int) error {
+	if maxPerBatch <= 0 {
+		maxPerBatch = len(rrsets)
+	}
+
+	for applied := 0; applied < len(rrsets); applied += maxPerBatch {
+		end := applied + maxPerBatch
+		if end > len(rrsets) {
+			end = len(rrsets)
+		}
+		if err := p.PatchZoneContext(ctx, name, rrsets[applied:end]); err != nil {
+			return ErrPatchBatch{Applied: applied, Err: err}
+		}
+	}
+	return nil
+}
+
+// PlanZonePatch returns the changes PatchZone would need to apply to make the RRsets of the named zone match desired,
+// as computed by authoritative.ReconcileRRsets, without applying them. RRsets in the zone which are not in desired
+// are deleted by the plan, so desired should include the zone's SOA and NS RRsets.
+func (p *Client) PlanZonePatch(name string, desired shared.RRsets) (authoritative.PatchRRSets, error) {
+	return p.PlanZonePatchContext(context.Background(), name, desired)
+}
+
+// PlanZonePatchContext is like PlanZonePatch but uses ctx for the requests it makes.
+func (p *Client) PlanZonePatchContext(ctx context.Context,
+	name string, desired shared.RRsets) (authoritative.PatchRRSets, error) {
+	zoneResponse, err := p.GetZoneContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return authoritative.ReconcileRRsets(desired, zoneResponse.RRsets), nil
+}
+
+// ReplaceRecords replaces the given RRsets in the named zone, creating them if they do not exist.
+func (p *Client) ReplaceRecords(name string, rrsets shared.RRsets) error {
+	return p.ReplaceRecordsContext(context.Background(), name, rrsets)
+}
+
+// ReplaceRecordsContext is like ReplaceRecords but uses ctx for the requests it makes.
+func (p *Client) ReplaceRecordsContext(ctx context.Context, name string, rrsets shared.RRsets) error {
+	return p.PatchZoneContext(ctx, name, authoritative.NewPatchRRSets(rrsets, authoritative.RRsetReplace))
+}
+
+// DeleteRecords removes the given RRsets from the named zone. RRsets are matched by name and type only, so all
+// records of a matching RRset are removed.
+func (p *Client) DeleteRecords(name string, rrsets shared.RRsets) error {
+	return p.DeleteRecordsContext(context.Background(), name, rrsets)
+}
+
+// DeleteRecordsContext is like DeleteRecords but uses ctx for the requests it makes.
+func (p *Client) DeleteRecordsContext(ctx context.Context, name string, rrsets shared.RRsets) error {
+	return p.PatchZoneContext(ctx, name, authoritative.NewPatchRRSets(rrsets, authoritative.RRSetDelete))
+}
+
+// SetRRsetComments replaces the comments on the RRset of the given name and type in the named zone, leaving its
+// records unchanged. comments must not be empty, since PowerDNS leaves the existing comments in place if none are
+// supplied.
+func (p *Client) SetRRsetComments(zone, name, rrtype string, comments []shared.Comment) error {
+	return p.SetRRsetCommentsContext(context.Background(), zone, name, rrtype, comments)
+}
+
+// SetRRsetCommentsContext is like SetRRsetComments but uses ctx for the requests it makes.
+func (p *Client) SetRRsetCommentsContext(ctx context.Context,
+	zone, name, rrtype string, comments []shared.Comment) error {
+	// Built directly rather than with NewPatchRRSets, since copying would turn the nil records into an empty
+	// array and delete them.
+	rrset := authoritative.PatchRRSet{
+		RRset:      shared.RRset{Name: name, Type: rrtype, Comments: comments},
+		ChangeType: authoritative.RRsetReplace,
+	}
+	return p.PatchZoneContext(ctx, zone, authoritative.PatchRRSets{rrset})
+}
+
+// canonicalName appends the trailing dot PowerDNS requires to a name, if it is missing.
+func canonicalName(name string) string {
+	if !strings.HasSuffix(name, ".") {
+		return name + "."
+	}
+	return name
+}
+
+// UpsertRecord sets the RRset of the given name and type in the named zone to contain exactly the given record
+// contents, creating it if it does not exist. Names are made fully qualified if they are not already. TXT contents
+// which are not already quoted are formatted with records.FormatTXT.
+func (p *Client) UpsertRecord(zone, name, rrtype string, ttl uint32, contents ...string) error {
+	return p.UpsertRecordContext(context.Background(), zone, name, rrtype, ttl, contents...)
+}
+
+// UpsertRecordContext is like UpsertRecord but uses ctx for the requests it makes.
+func (p *Client) UpsertRecordContext(ctx context.Context,
+	zone, name, rrtype string, ttl uint32, contents ...string) error {
+	if len(contents) == 0 {
+		return ErrClientRecordContentEmpty
+	}
+
+	rrset := shared.RRset{Name: canonicalName(name), Type: rrtype, TTL: ttl, Records: shared.Records{}}
+	for _, content := range contents {
+		if strings.EqualFold(rrtype, "TXT") {
+			content = txtContent(content)
+		}
+		rrset.Records = append(rrset.Records, shared.Record{Content: content})
+	}
+	return p.ReplaceRecordsContext(ctx, canonicalName(zone), shared.RRsets{rrset})
+}
+
+// DeleteRecord removes the RRset of the given name and type from the named zone. Names are made fully qualified if
+// they are not already. Deleting an RRset which does not exist is not an error.
+func (p *Client) DeleteRecord(zone, name, rrtype string) error {
+	return p.DeleteRecordContext(context.Background(), zone, name, rrtype)
+}
+
+// DeleteRecordContext is like DeleteRecord but uses ctx for the requests it makes.
+func (p *Client) DeleteRecordContext(ctx context.Context, zone, name, rrtype string) error {
+	rrset := shared.RRset{Name: canonicalName(name), Type: rrtype}
+	return p.DeleteRecordsContext(ctx, canonicalName(zone), shared.RRsets{rrset})
+}
+
+// DeleteRecordsOfType removes every RRset of the given type, e.g. "TXT", from the named zone in a single change.
+// The zone name is made fully qualified if it is not already. Nothing is changed if the zone has no RRsets of the
+// type.
+func (p *Client) DeleteRecordsOfType(zone, rrtype string) error {
+	return p.DeleteRecordsOfTypeContext(context.Background(), zone, rrtype)
+}
+
+// DeleteRecordsOfTypeContext is like DeleteRecordsOfType but uses ctx for the requests it makes.
+func (p *Client) DeleteRecordsOfTypeContext(ctx context.Context, zone, rrtype string) error {
+	zone = canonicalName(zone)
+
+	zoneResponse, err := p.GetZoneContext(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	rrsets := zoneResponse.RRsets.FilterByType(rrtype)
+	if len(rrsets) == 0 {
+		return nil
+	}
+	return p.DeleteRecordsContext(ctx, zone, rrsets)
+}